@@ -0,0 +1,92 @@
+package grules
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strconv"
+)
+
+// bucketRange is the value of a bucket rule, it matches buckets in
+// [From, To). A percentage p is the range [0, p).
+type bucketRange struct {
+	From float64
+	To   float64
+	raw  interface{}
+}
+
+// MarshalJSON will put the bucket range back into its original form
+func (b *bucketRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.raw)
+}
+
+// parseBucket will parse the value of a bucket rule when it is loaded. The
+// value is either a percentage, or an object like {"from": 10, "to": 35}.
+func parseBucket(v interface{}) (interface{}, error) {
+	b := bucketRange{raw: v}
+
+	switch t := v.(type) {
+	case float64:
+		b.To = t
+	case map[string]interface{}:
+		var okFrom, okTo bool
+		b.From, okFrom = t["from"].(float64)
+		b.To, okTo = t["to"].(float64)
+		if !okFrom || !okTo {
+			return nil, errors.New("bucket value must be an object with from and to")
+		}
+	default:
+		return nil, errors.New("bucket value must be a percentage or an object with from and to")
+	}
+
+	if b.From < 0 || b.To > 100 || b.From > b.To {
+		return nil, errors.New("bucket value must be between 0 and 100")
+	}
+
+	return &b, nil
+}
+
+// bucketOf will hash the key with the salt into a bucket in [0, 100) with a
+// resolution of 0.01. The hash is FNV-1a, and it only depends on the salt and
+// the key, so a key stays in the same bucket across processes and versions.
+func bucketOf(salt, key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	h.Write([]byte{':'})
+	h.Write([]byte(key))
+	return float64(h.Sum64()%10000) / 100
+}
+
+// bucketKey will return the string that a value is hashed as, numbers are
+// formatted without a trailing fraction so 1234 and 1234.0 share a bucket
+func bucketKey(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	}
+
+	if f, ok := toFloat64(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// bucket will return true if a hashes into the range b. The salt option is
+// hashed along with a so that different rollouts use different buckets.
+func bucket(a, b interface{}, options Options) bool {
+	r, ok := b.(*bucketRange)
+	if !ok {
+		return false
+	}
+
+	key, ok := bucketKey(a)
+	if !ok {
+		return false
+	}
+
+	salt, _ := options["salt"].(string)
+	n := bucketOf(salt, key)
+	return n >= r.From && n < r.To
+}
@@ -0,0 +1,111 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestBucketOf(t *testing.T) {
+	// These must never change, or users would move between buckets
+	cases := []struct {
+		salt, key string
+		expected  float64
+	}{
+		{salt: "checkout", key: "1234", expected: 93.21},
+		{salt: "search", key: "1234", expected: 58.81},
+		{salt: "checkout", key: "user-1", expected: 38.72},
+		{salt: "checkout", key: "user-2", expected: 85.05},
+	}
+
+	for i, c := range cases {
+		res := bucketOf(c.salt, c.key)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestBucketDistribution(t *testing.T) {
+	r := &bucketRange{To: 25}
+	options := Options{"salt": "checkout"}
+
+	var n int
+	for i := 0; i < 10000; i++ {
+		if bucket(fmt.Sprintf("%d", i), r, options) {
+			n++
+		}
+	}
+
+	if n < 2400 || n > 2600 {
+		t.Fatalf("expected about 25%% of keys to be in the bucket, got %d", n)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	cases := []struct {
+		args     []interface{}
+		options  Options
+		expected bool
+	}{
+		{args: []interface{}{"user-1", &bucketRange{To: 50}}, options: Options{"salt": "checkout"}, expected: true},
+		{args: []interface{}{"user-1", &bucketRange{To: 30}}, options: Options{"salt": "checkout"}, expected: false},
+		{args: []interface{}{"user-1", &bucketRange{From: 38.72, To: 38.73}}, options: Options{"salt": "checkout"}, expected: true},
+		{args: []interface{}{float64(1234), &bucketRange{From: 90, To: 100}}, options: Options{"salt": "checkout"}, expected: true},
+		{args: []interface{}{1234, &bucketRange{From: 90, To: 100}}, options: Options{"salt": "checkout"}, expected: true},
+		{args: []interface{}{"user-1", &bucketRange{To: 100}}, options: nil, expected: true},
+		{args: []interface{}{"user-1", &bucketRange{To: 0}}, options: nil, expected: false},
+		{args: []interface{}{true, &bucketRange{To: 100}}, options: nil, expected: false},
+		{args: []interface{}{"user-1", float64(100)}, options: nil, expected: false},
+	}
+
+	for i, c := range cases {
+		res := bucket(c.args[0], c.args[1], c.options)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	invalid := []string{
+		`{"comparator":"bucket","path":"user.id","value":"25"}`,
+		`{"comparator":"bucket","path":"user.id","value":101}`,
+		`{"comparator":"bucket","path":"user.id","value":{"from":10}}`,
+		`{"comparator":"bucket","path":"user.id","value":{"from":40,"to":30}}`,
+	}
+
+	for i, j := range invalid {
+		var r rule
+		err := json.Unmarshal([]byte(j), &r)
+		if err == nil {
+			t.Fatalf("expected case %d to fail to load", i)
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"or","rules":[{"comparator":"bucket","path":"user.id","value":25,"options":{"salt":"checkout"}},{"comparator":"bucket","path":"user.id","value":{"from":10,"to":35},"options":{"salt":"search"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func BenchmarkBucket(b *testing.B) {
+	r := &bucketRange{To: 25}
+	options := Options{"salt": "checkout"}
+
+	for i := 0; i < b.N; i++ {
+		bucket("user-1", r, options)
+	}
+}
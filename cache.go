@@ -0,0 +1,127 @@
+package grules
+
+// cachedValue is the value of a path that has been looked up once in an
+// evaluation
+type cachedValue struct {
+	done  bool
+	val   interface{}
+	found bool
+	vals  []interface{}
+}
+
+// cachePaths will give each path that more than one of the engine's top
+// level rules look up a slot in the cache of an evaluation, paths are the
+// same if they print the same. It will return the slots by path, counting
+// from 1, and the number of slots. The slots belong to the engine rather
+// than the paths, since rules can be moved between engines through
+// Composites. The rules nested in a quantifier are not cached, their paths
+// are relative to each element.
+func (e Engine) cachePaths() (map[*path]int, int) {
+	paths := make(map[string][]*path)
+	var keys []string
+	for _, c := range e.Composites {
+		for _, r := range c.Rules {
+			r.paths(func(p *path) {
+				if _, ok := paths[p.key]; !ok {
+					keys = append(keys, p.key)
+				}
+				paths[p.key] = append(paths[p.key], p)
+			})
+		}
+	}
+
+	slots := make(map[*path]int)
+	var n int
+	for _, key := range keys {
+		if len(paths[key]) < 2 {
+			continue
+		}
+		n++
+		for _, p := range paths[key] {
+			slots[p] = n
+		}
+	}
+	return slots, n
+}
+
+// paths will call fn with every compiled path the rule looks up in the
+// evaluation it is given, which leaves out the paths of the rules nested in
+// its quantifier
+func (r rule) paths(fn func(p *path)) {
+	switch {
+	case r.Quantifier != nil:
+		if r.Quantifier.path != nil {
+			fn(r.Quantifier.path)
+		}
+	case r.Expr != nil:
+		r.Expr.paths(fn)
+	case r.target != nil && r.target.path != nil:
+		fn(r.target.path)
+	}
+
+	switch v := r.Value.(type) {
+	case *pathValue:
+		fn(v.path)
+	case *exprValue:
+		v.expr.paths(fn)
+	}
+}
+
+// slot will return the place of p in the cache of the evaluation, or -1 if
+// it is not cached, e.g. because it belongs to a rule of another engine
+func (ev *evaluation) slot(p *path) int {
+	if ev.cache == nil {
+		return -1
+	}
+	n := ev.slots[p]
+	if n < 1 || n > len(ev.cache) {
+		return -1
+	}
+	return n - 1
+}
+
+// lookup will return the value at the compiled path p, from the cache if a
+// rule has already looked it up in this evaluation. A path that yields many
+// values returns the first of them.
+func (ev *evaluation) lookup(p *path) (interface{}, bool) {
+	i := ev.slot(p)
+	if i < 0 {
+		return sourceLookup(ev.source, p)
+	}
+	if p.multi {
+		vals := ev.values(p)
+		if len(vals) == 0 {
+			return nil, false
+		}
+		return vals[0], true
+	}
+
+	c := &ev.cache[i]
+	if c.done {
+		ev.cacheHits++
+		return c.val, c.found
+	}
+	ev.cacheMisses++
+	c.val, c.found = sourceLookup(ev.source, p)
+	c.done = true
+	return c.val, c.found
+}
+
+// values will return every value the compiled path p yields, from the cache
+// if a rule has already looked them up in this evaluation
+func (ev *evaluation) values(p *path) []interface{} {
+	i := ev.slot(p)
+	if i < 0 {
+		return sourceValues(ev.source, p)
+	}
+
+	c := &ev.cache[i]
+	if c.done {
+		ev.cacheHits++
+		return c.vals
+	}
+	ev.cacheMisses++
+	c.vals = sourceValues(ev.source, p)
+	c.done = true
+	return c.vals
+}
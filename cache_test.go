@@ -0,0 +1,193 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEngine_cachePaths(t *testing.T) {
+	j := `{"composites":[
+		{"operator":"and","rules":[
+			{"comparator":"eq","path":"user.country","value":"US"},
+			{"comparator":"eq","path":"user.name","value":"Trevor"},
+			{"comparator":"eq","path":"orders.*.status","value":"shipped"}
+		]},
+		{"operator":"or","rules":[
+			{"comparator":"neq","path":"$.user.country","value":"CA"},
+			{"comparator":"eq","path":"lower(user.country)","value":"us"},
+			{"comparator":"eq","path":"orders[*].status","value":"shipped","match":"all"},
+			{"any":"orders","rule":{"comparator":"eq","path":"user.country","value":"US"}}
+		]}
+	]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.cacheSize != 2 {
+		t.Fatalf("expected 2 cached paths, got %d", e.cacheSize)
+	}
+
+	rules := append(e.Composites[0].Rules, e.Composites[1].Rules...)
+	slot := func(p *path) int {
+		return e.slots[p]
+	}
+	country := slot(rules[0].target.path)
+	if country == 0 {
+		t.Fatal("expected user.country to be cached")
+	}
+	if slot(rules[3].target.path) != country || slot(rules[4].target.path) != country {
+		t.Fatal("expected rules with the same path to share a slot")
+	}
+	if slot(rules[2].target.path) != slot(rules[5].target.path) || slot(rules[2].target.path) == 0 {
+		t.Fatal("expected orders.*.status to be cached in one slot")
+	}
+	if slot(rules[1].target.path) != 0 {
+		t.Fatal("expected user.name not to be cached")
+	}
+	if slot(rules[6].Quantifier.path) != 0 || slot(rules[6].Quantifier.Rule.target.path) != 0 {
+		t.Fatal("expected paths used once, and nested rules, not to be cached")
+	}
+}
+
+func TestEngine_cacheCombinedEngines(t *testing.T) {
+	newEngine := func(j string) Engine {
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	x := newEngine(`{"composites":[{"operator":"and","rules":[
+		{"comparator":"eq","path":"x","value":1},
+		{"comparator":"gte","path":"x","value":1}
+	]}]}`)
+	y := newEngine(`{"composites":[{"operator":"and","rules":[
+		{"comparator":"eq","path":"a","value":"b"},
+		{"comparator":"eq","path":"a","value":"b"},
+		{"comparator":"eq","path":"y","value":2},
+		{"comparator":"gte","path":"y","value":2}
+	]}]}`)
+	props := map[string]interface{}{"a": "b", "x": 1.0, "y": 2.0}
+
+	tests := []struct {
+		name       string
+		composites []composite
+	}{
+		{"appended", append(append([]composite{}, x.Composites...), y.Composites...)},
+		{"other engine first", append(append([]composite{}, y.Composites...), x.Composites...)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := x
+			e.Composites = tc.composites
+			if !e.Evaluate(props) {
+				t.Fatal("expected engine to be true")
+			}
+		})
+	}
+}
+
+func TestEvaluation_cache(t *testing.T) {
+	j := `{"composites":[
+		{"operator":"and","rules":[{"comparator":"eq","path":"user.country","value":"US"}]},
+		{"operator":"and","rules":[{"comparator":"eq","path":"lower(user.country)","value":"us"}]},
+		{"operator":"and","rules":[{"comparator":"exists","path":"user.country"}]},
+		{"operator":"and","rules":[{"comparator":"eq","path":"orders.*.status","value":"shipped"}]},
+		{"operator":"and","rules":[{"comparator":"eq","path":"orders.*.status","value":"shipped","match":"all"}]},
+		{"operator":"and","rules":[{"any":"orders","rule":{"comparator":"eq","path":"status","value":"shipped"}}]},
+		{"operator":"and","rules":[{"all":"orders","rule":{"comparator":"eq","path":"status","value":"shipped"}}]}
+	]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := map[string]interface{}{
+		"user": map[string]interface{}{"country": "US"},
+		"orders": []interface{}{
+			map[string]interface{}{"status": "shipped"},
+			map[string]interface{}{"status": "shipped"},
+		},
+	}
+	ev := e.newEvaluation(nil, MapSource(props))
+	if !e.evaluate(ev) {
+		t.Fatal("expected engine to be true")
+	}
+	if ev.cacheMisses != 3 || ev.cacheHits != 4 {
+		t.Fatalf("expected 3 misses and 4 hits, got %d and %d", ev.cacheMisses, ev.cacheHits)
+	}
+
+	props["user"] = map[string]interface{}{"country": "CA"}
+	if e.Evaluate(props) {
+		t.Fatal("expected a new evaluation not to use the cache of the last")
+	}
+
+	delete(props, "user")
+	ev = e.newEvaluation(nil, MapSource(props))
+	if e.evaluate(ev) {
+		t.Fatal("expected engine to be false")
+	}
+	if ev.cacheMisses != 1 || ev.cacheHits != 0 {
+		t.Fatalf("expected the first composite to stop the evaluation, got %d misses and %d hits", ev.cacheMisses, ev.cacheHits)
+	}
+}
+
+// sharedPathsEngine will return an engine with n composites that all refer
+// to user.country, and each refer to a path of their own
+func sharedPathsEngine(b *testing.B, n int) Engine {
+	var composites []string
+	for i := 0; i < n; i++ {
+		composites = append(composites, fmt.Sprintf(`{"operator":"and","rules":[{"comparator":"eq","path":"user.country","value":"US"},{"comparator":"oneof","path":"user.address.state","value":["GA","NY"]},{"comparator":"gte","path":"user.scores.%d","value":1}]}`, i))
+	}
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[` + strings.Join(composites, ",") + `]}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return e
+}
+
+// sharedPathsProps are the props for a sharedPathsEngine
+func sharedPathsProps(n int) map[string]interface{} {
+	scores := make([]interface{}, n)
+	for i := range scores {
+		scores[i] = float64(i + 1)
+	}
+	return map[string]interface{}{
+		"user": map[string]interface{}{
+			"country": "US",
+			"address": map[string]interface{}{"state": "GA"},
+			"scores":  scores,
+		},
+	}
+}
+
+func BenchmarkEngine_EvaluateSharedPaths(b *testing.B) {
+	e := sharedPathsEngine(b, 12)
+	props := MapSource(sharedPathsProps(12))
+
+	var hits, misses int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ev := e.newEvaluation(nil, props)
+		e.evaluate(ev)
+		hits += ev.cacheHits
+		misses += ev.cacheMisses
+	}
+	b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+	b.ReportMetric(float64(misses)/float64(b.N), "misses/op")
+}
+
+func BenchmarkEngine_EvaluateSharedPathsUncached(b *testing.B) {
+	e := sharedPathsEngine(b, 12)
+	e.cacheSize = 0
+	props := MapSource(sharedPathsProps(12))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.evaluate(e.newEvaluation(nil, props))
+	}
+}
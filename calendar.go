@@ -0,0 +1,234 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PathNow is a reserved path that resolves to the engine's clock instead of
+// a value in the props, so calendar rules can match the current time
+const PathNow = "$now"
+
+// weekdays are the names a weekday rule accepts for each day
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// calendarValue is the value of a calendar rule, it is parsed when the rule
+// is loaded. Days is a bit set of weekdays, From and To are either minutes
+// into the day or dates, depending on the comparator.
+type calendarValue struct {
+	days     uint8
+	from, to int
+	fromDate time.Time
+	toDate   time.Time
+	location *time.Location
+	raw      interface{}
+}
+
+// MarshalJSON will put the calendar value back into its original form
+func (c *calendarValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.raw)
+}
+
+// parseLocation will load the timezone of a calendar value from the system
+// tzdata, it defaults to UTC
+func parseLocation(name string, m map[string]interface{}) (*time.Location, error) {
+	tz, ok := m["timezone"]
+	if !ok {
+		return time.UTC, nil
+	}
+
+	s, ok := tz.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s timezone must be a string", name)
+	}
+
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s timezone: %v", name, err)
+	}
+	return loc, nil
+}
+
+// parseWeekday will parse the value of a weekday rule, e.g.
+// {"days": ["mon", "tue"], "timezone": "America/New_York"}
+func parseWeekday(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("weekday value must be an object with days")
+	}
+
+	days, ok := m["days"].([]interface{})
+	if !ok || len(days) == 0 {
+		return nil, fmt.Errorf("weekday days must be a list of days")
+	}
+
+	c := calendarValue{raw: v}
+	for _, d := range days {
+		s, _ := d.(string)
+		wd, ok := weekdays[strings.ToLower(s)]
+		if !ok {
+			return nil, fmt.Errorf("weekday %v is not a day", d)
+		}
+		c.days |= 1 << uint(wd)
+	}
+
+	var err error
+	c.location, err = parseLocation("weekday", m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// parseTimeBetween will parse the value of a time_between rule, e.g.
+// {"from": "22:00", "to": "06:00", "timezone": "America/New_York"}
+func parseTimeBetween(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("time_between value must be an object with from and to")
+	}
+
+	c := calendarValue{raw: v}
+	for _, f := range []struct {
+		key string
+		dst *int
+	}{{"from", &c.from}, {"to", &c.to}} {
+		s, _ := m[f.key].(string)
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return nil, fmt.Errorf("time_between %s must be a time like 09:00", f.key)
+		}
+		*f.dst = t.Hour()*60 + t.Minute()
+	}
+
+	var err error
+	c.location, err = parseLocation("time_between", m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// parseDateBetween will parse the value of a date_between rule, e.g.
+// {"from": "2026-11-01", "to": "2026-11-30", "timezone": "America/New_York"}
+func parseDateBetween(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("date_between value must be an object with from and to")
+	}
+
+	c := calendarValue{raw: v}
+	for _, f := range []struct {
+		key string
+		dst *time.Time
+	}{{"from", &c.fromDate}, {"to", &c.toDate}} {
+		s, _ := m[f.key].(string)
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, fmt.Errorf("date_between %s must be a date like 2006-01-02", f.key)
+		}
+		*f.dst = t
+	}
+	if c.toDate.Before(c.fromDate) {
+		return nil, fmt.Errorf("date_between from must not be after to")
+	}
+
+	var err error
+	c.location, err = parseLocation("date_between", m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// toTime will convert a prop into a time. Props can be a time.Time, an
+// RFC 3339 string or a number of seconds since the Unix epoch.
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, false
+		}
+		return *t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return parsed, true
+	}
+
+	if f, ok := toFloat64(v); ok {
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+	}
+	return time.Time{}, false
+}
+
+// calendar will convert a and b into a time in the timezone of b
+func calendar(a, b interface{}) (time.Time, *calendarValue, bool) {
+	c, ok := b.(*calendarValue)
+	if !ok {
+		return time.Time{}, nil, false
+	}
+
+	t, ok := toTime(a)
+	if !ok {
+		return time.Time{}, nil, false
+	}
+
+	return t.In(c.location), c, true
+}
+
+// weekday will return true if the time a falls on one of the days in b
+func weekday(a, b interface{}) bool {
+	t, c, ok := calendar(a, b)
+	if !ok {
+		return false
+	}
+
+	return c.days&(1<<uint(t.Weekday())) != 0
+}
+
+// timeBetween will return true if the time of day of a is in [from, to) of
+// b. A range that ends before it starts crosses midnight, e.g. 22:00 to
+// 06:00.
+func timeBetween(a, b interface{}) bool {
+	t, c, ok := calendar(a, b)
+	if !ok {
+		return false
+	}
+
+	m := t.Hour()*60 + t.Minute()
+	if c.from <= c.to {
+		return m >= c.from && m < c.to
+	}
+	return m >= c.from || m < c.to
+}
+
+// dateBetween will return true if the date of a is between the from and to
+// dates of b, inclusive
+func dateBetween(a, b interface{}) bool {
+	t, c, ok := calendar(a, b)
+	if !ok {
+		return false
+	}
+
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return !d.Before(c.fromDate) && !d.After(c.toDate)
+}
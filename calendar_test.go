@@ -0,0 +1,162 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, parse func(v interface{}) (interface{}, error), j string) interface{} {
+	var raw interface{}
+	err := json.Unmarshal([]byte(j), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestWeekday(t *testing.T) {
+	weekdaysNY := mustParse(t, parseWeekday, `{"days":["mon","Tuesday","wed","thu","fri"],"timezone":"America/New_York"}`)
+	weekendUTC := mustParse(t, parseWeekday, `{"days":["sat","sun"]}`)
+
+	cases := []testCase{
+		// Monday 09:30 in New York
+		testCase{args: []interface{}{"2024-01-01T14:30:00Z", weekdaysNY}, expected: true},
+		// Monday 03:00 in UTC is still Sunday in New York
+		testCase{args: []interface{}{"2024-01-01T03:00:00Z", weekdaysNY}, expected: false},
+		testCase{args: []interface{}{"2024-01-01T03:00:00Z", weekendUTC}, expected: false},
+		testCase{args: []interface{}{time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC), weekendUTC}, expected: true},
+		testCase{args: []interface{}{float64(1704067200), weekendUTC}, expected: false},
+		testCase{args: []interface{}{"not a time", weekendUTC}, expected: false},
+		testCase{args: []interface{}{"2024-01-06T12:00:00Z", []interface{}{"sat"}}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := weekday(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestTimeBetween(t *testing.T) {
+	businessNY := mustParse(t, parseTimeBetween, `{"from":"09:00","to":"17:00","timezone":"America/New_York"}`)
+	overnight := mustParse(t, parseTimeBetween, `{"from":"22:00","to":"06:00"}`)
+
+	cases := []testCase{
+		testCase{args: []interface{}{"2024-01-01T14:00:00Z", businessNY}, expected: true},
+		testCase{args: []interface{}{"2024-01-01T13:59:00Z", businessNY}, expected: false},
+		testCase{args: []interface{}{"2024-01-01T22:00:00Z", businessNY}, expected: false},
+		// Daylight saving time moves the offset to -04:00
+		testCase{args: []interface{}{"2024-07-01T13:00:00Z", businessNY}, expected: true},
+		testCase{args: []interface{}{"2024-01-01T23:30:00Z", overnight}, expected: true},
+		testCase{args: []interface{}{"2024-01-01T05:59:00Z", overnight}, expected: true},
+		testCase{args: []interface{}{"2024-01-01T06:00:00Z", overnight}, expected: false},
+		testCase{args: []interface{}{"2024-01-01T12:00:00Z", overnight}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := timeBetween(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestDateBetween(t *testing.T) {
+	novemberNY := mustParse(t, parseDateBetween, `{"from":"2024-11-01","to":"2024-11-30","timezone":"America/New_York"}`)
+
+	cases := []testCase{
+		testCase{args: []interface{}{"2024-11-01T04:00:00Z", novemberNY}, expected: true},
+		testCase{args: []interface{}{"2024-11-01T03:59:00Z", novemberNY}, expected: false},
+		testCase{args: []interface{}{"2024-12-01T04:59:00Z", novemberNY}, expected: true},
+		testCase{args: []interface{}{"2024-12-01T05:00:00Z", novemberNY}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := dateBetween(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestParseCalendar(t *testing.T) {
+	invalid := []string{
+		`{"comparator":"weekday","path":"at","value":["mon"]}`,
+		`{"comparator":"weekday","path":"at","value":{"days":["someday"]}}`,
+		`{"comparator":"weekday","path":"at","value":{"days":["mon"],"timezone":"Nowhere/Special"}}`,
+		`{"comparator":"time_between","path":"at","value":{"from":"9am","to":"17:00"}}`,
+		`{"comparator":"time_between","path":"at","value":{"from":"09:00"}}`,
+		`{"comparator":"date_between","path":"at","value":{"from":"2024-11-30","to":"2024-11-01"}}`,
+		`{"comparator":"date_between","path":"at","value":{"from":"2024-11-01","to":"tomorrow"}}`,
+	}
+
+	for i, j := range invalid {
+		var r rule
+		err := json.Unmarshal([]byte(j), &r)
+		if err == nil {
+			t.Fatalf("expected case %d to fail to load", i)
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"weekday","path":"$now","value":{"days":["mon","tue","wed","thu","fri"],"timezone":"America/New_York"}},{"comparator":"time_between","path":"$now","value":{"from":"09:00","timezone":"America/New_York","to":"17:00"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func TestEngine_WithClock(t *testing.T) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"weekday","path":"$now","value":{"days":["mon","tue","wed","thu","fri"],"timezone":"America/New_York"}},{"comparator":"time_between","path":"$now","value":{"from":"09:00","to":"17:00","timezone":"America/New_York"}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := e.WithClock(func() time.Time {
+		return time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+	})
+	if open.Evaluate(nil) != true {
+		t.Fatal("expected promotion to be open on Monday at 10:00")
+	}
+
+	closed := e.WithClock(func() time.Time {
+		return time.Date(2024, 1, 6, 15, 0, 0, 0, time.UTC)
+	})
+	if closed.Evaluate(nil) != false {
+		t.Fatal("expected promotion to be closed on Saturday")
+	}
+}
+
+func BenchmarkTimeBetween(b *testing.B) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(`{"from":"09:00","to":"17:00","timezone":"America/New_York"}`), &raw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	v, err := parseTimeBetween(raw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	at := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		timeBetween(at, v)
+	}
+}
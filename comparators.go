@@ -1,6 +1,8 @@
 package grules
 
 import (
+	"math"
+	"reflect"
 	"regexp"
 	"strings"
 )
@@ -10,8 +12,59 @@ import (
 // false
 type Comparator func(a, b interface{}) bool
 
-// equal will return true if a == b
+// Compare will call the comparator, a Comparator ignores the options of the
+// rule
+func (c Comparator) Compare(a, b interface{}, options Options) bool {
+	return c(a, b)
+}
+
+// Options are the arguments that a rule gives to its comparator, e.g.
+// {"case_insensitive": true} for the regex comparator
+type Options map[string]interface{}
+
+// Comparer is the interface every comparator in an engine satisfies, it is
+// given the options of the rule being evaluated along with the two values
+type Comparer interface {
+	Compare(a, b interface{}, options Options) bool
+}
+
+// Capturer is a Comparer that can also capture named values while it
+// compares, e.g. the named groups of a regular expression. The captures of
+// matching rules are returned by Engine.EvaluateCaptures.
+type Capturer interface {
+	Comparer
+	Capture(a, b interface{}, options Options) (bool, map[string]string)
+}
+
+// OptionsComparator is a function that evaluates two values like a
+// Comparator, but is also given the options of the rule being evaluated
+type OptionsComparator func(a, b interface{}, options Options) bool
+
+// Compare will call the comparator with the options of the rule
+func (c OptionsComparator) Compare(a, b interface{}, options Options) bool {
+	return c(a, b, options)
+}
+
+// presenceComparator is evaluated before a rule gives up on a missing or
+// null value. found will be false if the path does not exist in the props
+// at all, val will be nil if the path exists but holds a null value.
+type presenceComparator func(val interface{}, found bool) bool
+
+// equal will return true if a == b. Objects and arrays are never equal, they
+// cannot be compared with ==.
 func equal(a, b interface{}) bool {
+	switch at := a.(type) {
+	case string:
+		bt, ok := b.(string)
+		return ok && at == bt
+	case float64:
+		bt, ok := b.(float64)
+		return ok && at == bt
+	}
+
+	if !hashable(a) || !hashable(b) {
+		return false
+	}
 	return a == b
 }
 
@@ -20,6 +73,73 @@ func notEqual(a, b interface{}) bool {
 	return !equal(a, b)
 }
 
+// equalWithin will return a comparator like equal that treats two float64
+// values as equal if they are no more than epsilon apart
+func equalWithin(epsilon float64) Comparator {
+	return func(a, b interface{}) bool {
+		at, ok := a.(float64)
+		if !ok {
+			return equal(a, b)
+		}
+		bt, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		return math.Abs(at-bt) <= epsilon
+	}
+}
+
+// notEqualWithin will return a comparator like notEqual that treats two
+// float64 values as equal if they are no more than epsilon apart
+func notEqualWithin(epsilon float64) Comparator {
+	eq := equalWithin(epsilon)
+	return func(a, b interface{}) bool {
+		return !eq(a, b)
+	}
+}
+
+// approxEqual will return a comparator that is true if the numbers a and b
+// are close. The epsilon option is the absolute tolerance and defaults to
+// the given epsilon, the relative option is a tolerance relative to the
+// larger of a and b. The numbers are close if they are within either.
+func approxEqual(epsilon float64) OptionsComparator {
+	return func(a, b interface{}, options Options) bool {
+		at, ok := toFloat64(a)
+		if !ok {
+			return false
+		}
+		bt, ok := toFloat64(b)
+		if !ok {
+			return false
+		}
+
+		abs := epsilon
+		if v, ok := options["epsilon"].(float64); ok {
+			abs = v
+		}
+		rel, _ := options["relative"].(float64)
+
+		diff := math.Abs(at - bt)
+		return diff <= abs || diff <= rel*math.Max(math.Abs(at), math.Abs(bt))
+	}
+}
+
+// approxNotEqual will return a comparator that is true if the numbers a and b
+// are not close, it takes the same options as approxEqual. It is false if
+// either value is not a number.
+func approxNotEqual(epsilon float64) OptionsComparator {
+	eq := approxEqual(epsilon)
+	return func(a, b interface{}, options Options) bool {
+		if _, ok := toFloat64(a); !ok {
+			return false
+		}
+		if _, ok := toFloat64(b); !ok {
+			return false
+		}
+		return !eq(a, b, options)
+	}
+}
+
 // lessThan will return true if a < b
 func lessThan(a, b interface{}) bool {
 	switch a.(type) {
@@ -131,6 +251,7 @@ func greaterThanEqual(a, b interface{}) bool {
 	}
 }
 
+// regex will return true if a matches the regular expression b
 func regex(a, b interface{}) bool {
 	switch a.(type) {
 	case string:
@@ -154,6 +275,75 @@ func regex(a, b interface{}) bool {
 	}
 }
 
+// regexWithOptions will return true if a matches the regular expression b.
+// The case_insensitive option will ignore case when matching.
+func regexWithOptions(a, b interface{}, options Options) bool {
+	if ci, _ := options["case_insensitive"].(bool); ci {
+		bt, ok := b.(string)
+		if !ok {
+			return false
+		}
+		b = "(?i)" + bt
+	}
+
+	return regex(a, b)
+}
+
+// regexCapture will return true if a matches the regular expression b, along
+// with the values of any named groups. It takes the same options as
+// regexWithOptions.
+func regexCapture(a, b interface{}, options Options) (bool, map[string]string) {
+	at, ok := a.(string)
+	if !ok {
+		return false, nil
+	}
+	bt, ok := b.(string)
+	if !ok {
+		return false, nil
+	}
+	if ci, _ := options["case_insensitive"].(bool); ci {
+		bt = "(?i)" + bt
+	}
+
+	r, err := regexp.Compile(bt)
+	if err != nil {
+		return false, nil
+	}
+
+	match := r.FindStringSubmatch(at)
+	if match == nil {
+		return false, nil
+	}
+
+	var captures map[string]string
+	for i, name := range r.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if captures == nil {
+			captures = make(map[string]string)
+		}
+		captures[name] = match[i]
+	}
+
+	return true, captures
+}
+
+// regexComparer is the regex comparator, it captures the named groups of the
+// regular expression
+type regexComparer struct{}
+
+// Compare will return true if a matches the regular expression b
+func (regexComparer) Compare(a, b interface{}, options Options) bool {
+	return regexWithOptions(a, b, options)
+}
+
+// Capture will return true if a matches the regular expression b, along with
+// the values of any named groups
+func (regexComparer) Capture(a, b interface{}, options Options) (bool, map[string]string) {
+	return regexCapture(a, b, options)
+}
+
 // contains will return true if a contains b. a can be a slice
 // or a string.  If you need b to be a slice consider using oneOf
 func contains(a, b interface{}) bool {
@@ -177,7 +367,8 @@ func contains(a, b interface{}) bool {
 		case string:
 			return strings.Contains(a.(string), b.(string))
 		default:
-			return false
+			found, _ := containsElement(a, bt)
+			return found
 		}
 	case float64:
 		switch at := a.(type) {
@@ -195,7 +386,8 @@ func contains(a, b interface{}) bool {
 				}
 			}
 		default:
-			return false
+			found, _ := containsElement(a, bt)
+			return found
 		}
 	default:
 		return false
@@ -228,7 +420,8 @@ func notContains(a, b interface{}) bool {
 		case string:
 			return !strings.Contains(a.(string), b.(string))
 		default:
-			return false
+			found, ok := containsElement(a, bt)
+			return ok && !found
 		}
 	case float64:
 		switch at := a.(type) {
@@ -247,7 +440,8 @@ func notContains(a, b interface{}) bool {
 			}
 			return true
 		default:
-			return false
+			found, ok := containsElement(a, bt)
+			return ok && !found
 		}
 	default:
 		return false
@@ -261,6 +455,10 @@ func oneOf(a, b interface{}) bool {
 		return false
 	}
 
+	if !hashable(a) {
+		return false
+	}
+
 	_, found := m[a]
 	if found {
 		return true
@@ -276,6 +474,10 @@ func noneOf(a, b interface{}) bool {
 		return false
 	}
 
+	if !hashable(a) {
+		return true
+	}
+
 	_, found := m[a]
 	if !found {
 		return true
@@ -283,3 +485,241 @@ func noneOf(a, b interface{}) bool {
 
 	return false
 }
+
+// exists will return true if the path exists, even if its value is null
+func exists(val interface{}, found bool) bool {
+	return found
+}
+
+// notExists will return true if the path does not exist
+func notExists(val interface{}, found bool) bool {
+	return !found
+}
+
+// isNull will return true if the path exists and its value is null
+func isNull(val interface{}, found bool) bool {
+	return found && val == nil
+}
+
+// isEmpty will return true if the path does not exist, is null, or holds
+// an empty string, slice or map
+func isEmpty(val interface{}, found bool) bool {
+	if !found || val == nil {
+		return true
+	}
+
+	switch t := val.(type) {
+	case string:
+		return t == ""
+	case []interface{}:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	default:
+		return false
+	}
+}
+
+// notEmpty will return true if the path exists and holds a value that is
+// not null, an empty string, an empty slice or an empty map
+func notEmpty(val interface{}, found bool) bool {
+	return !isEmpty(val, found)
+}
+
+// eachElement will call fn with every element of the slice a until fn
+// returns false. It reports whether a was a slice it knows how to walk,
+// slices of other types are walked with reflection and their elements are
+// made plain, see plainValue.
+func eachElement(a interface{}, fn func(v interface{}) bool) bool {
+	switch at := a.(type) {
+	case []interface{}:
+		for _, v := range at {
+			if !fn(v) {
+				break
+			}
+		}
+	case []string:
+		for _, v := range at {
+			if !fn(v) {
+				break
+			}
+		}
+	case []float64:
+		for _, v := range at {
+			if !fn(v) {
+				break
+			}
+		}
+	case nil:
+		return false
+	default:
+		rv := reflect.ValueOf(a)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return false
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if !fn(plainValue(rv.Index(i))) {
+				break
+			}
+		}
+	}
+
+	return true
+}
+
+// containsElement will return true if the slice a, of any type, has an
+// element equal to b, which is a string or a float64. ok is false if a is
+// not a slice. Elements are compared without boxing them, so that the common
+// slice types that contains handles itself stay free of allocations.
+func containsElement(a, b interface{}) (found, ok bool) {
+	rv := reflect.ValueOf(a)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false, false
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if plainEqual(rv.Index(i), b) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// plainEqual will return true if the value in rv, made plain, is equal to b,
+// see plainValue
+func plainEqual(rv reflect.Value, b interface{}) bool {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+
+	switch bt := b.(type) {
+	case string:
+		return rv.Kind() == reflect.String && rv.String() == bt
+	case float64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()) == bt
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()) == bt
+		case reflect.Float32, reflect.Float64:
+			return rv.Float() == bt
+		}
+	}
+	return false
+}
+
+// hashable will return true if v can be a key of a set, or be compared with
+// ==, without panicking. Objects and arrays, e.g. a map[string]interface{},
+// cannot, so they are never in a set.
+func hashable(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, float64, bool:
+		return true
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return reflect.ValueOf(v).Comparable()
+}
+
+// containsAll will return true if the slice a contains every element of the
+// set b
+func containsAll(a, b interface{}) bool {
+	m, ok := b.(map[interface{}]struct{})
+	if !ok {
+		return false
+	}
+
+	seen := make(map[interface{}]struct{}, len(m))
+	ok = eachElement(a, func(v interface{}) bool {
+		if !hashable(v) {
+			return true
+		}
+		if _, found := m[v]; found {
+			seen[v] = struct{}{}
+		}
+		return len(seen) < len(m)
+	})
+	if !ok {
+		return false
+	}
+
+	return len(seen) == len(m)
+}
+
+// containsAny will return true if the slice a contains at least one element
+// of the set b
+func containsAny(a, b interface{}) bool {
+	m, ok := b.(map[interface{}]struct{})
+	if !ok {
+		return false
+	}
+
+	var found bool
+	eachElement(a, func(v interface{}) bool {
+		if hashable(v) {
+			_, found = m[v]
+		}
+		return !found
+	})
+
+	return found
+}
+
+// containsNone will return true if the slice a contains no element of the set
+// b. It will return false if a is not a slice.
+func containsNone(a, b interface{}) bool {
+	m, ok := b.(map[interface{}]struct{})
+	if !ok {
+		return false
+	}
+
+	var found bool
+	ok = eachElement(a, func(v interface{}) bool {
+		if hashable(v) {
+			_, found = m[v]
+		}
+		return !found
+	})
+
+	return ok && !found
+}
+
+// subsetOf will return true if every element of the slice a is in the set b
+func subsetOf(a, b interface{}) bool {
+	m, ok := b.(map[interface{}]struct{})
+	if !ok {
+		return false
+	}
+
+	all := true
+	ok = eachElement(a, func(v interface{}) bool {
+		all = hashable(v)
+		if all {
+			_, all = m[v]
+		}
+		return all
+	})
+
+	return ok && all
+}
+
+// supersetOf will return true if the slice a holds every element of the set
+// b, it is the same as containsAll
+func supersetOf(a, b interface{}) bool {
+	return containsAll(a, b)
+}
+
+// intersects will return true if the slice a and the set b share at least
+// one element, it is the same as containsAny
+func intersects(a, b interface{}) bool {
+	return containsAny(a, b)
+}
@@ -18,6 +18,8 @@ func TestEqual(t *testing.T) {
 		testCase{args: []interface{}{float64(1), float64(0)}, expected: false},
 		testCase{args: []interface{}{float64(1.1), float64(1.1)}, expected: true},
 		testCase{args: []interface{}{float64(1.1), float64(0.1)}, expected: false},
+		testCase{args: []interface{}{map[string]interface{}{"a": "b"}, map[string]interface{}{"a": "b"}}, expected: false},
+		testCase{args: []interface{}{[]interface{}{"a"}, "a"}, expected: false},
 	}
 
 	for i, c := range cases {
@@ -177,6 +179,54 @@ func TestRegex(t *testing.T) {
 	}
 }
 
+func TestRegexWithOptions(t *testing.T) {
+	cases := []struct {
+		args     []interface{}
+		options  Options
+		expected bool
+	}{
+		{args: []interface{}{"ABC", "^abc$"}, options: nil, expected: false},
+		{args: []interface{}{"ABC", "^abc$"}, options: Options{"case_insensitive": true}, expected: true},
+		{args: []interface{}{"ABC", "^abc$"}, options: Options{"case_insensitive": false}, expected: false},
+		{args: []interface{}{float64(1), "1"}, options: Options{"case_insensitive": true}, expected: false},
+		{args: []interface{}{"1", float64(1)}, options: Options{"case_insensitive": true}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := regexWithOptions(c.args[0], c.args[1], c.options)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestRegexCapture(t *testing.T) {
+	matched, captures := regexCapture("trevor@example.com", "@(?P<domain>[^@]+)$", nil)
+	if !matched || captures["domain"] != "example.com" {
+		t.Fatalf("expected domain to be captured, got %v", captures)
+	}
+
+	matched, captures = regexCapture("trevor@EXAMPLE.com", "@(?P<domain>example)\\.com$", Options{"case_insensitive": true})
+	if !matched || captures["domain"] != "EXAMPLE" {
+		t.Fatalf("expected case insensitive domain to be captured, got %v", captures)
+	}
+
+	matched, captures = regexCapture("abc", "(b)", nil)
+	if !matched || captures != nil {
+		t.Fatalf("expected unnamed groups to not be captured, got %v", captures)
+	}
+
+	matched, _ = regexCapture("trevor", "@(?P<domain>[^@]+)$", nil)
+	if matched {
+		t.Fatal("expected regex not to match")
+	}
+
+	matched, _ = regexCapture(float64(1), "1", nil)
+	if matched {
+		t.Fatal("expected non string to not match")
+	}
+}
+
 func BenchmarkRegex(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		regex("a", "a")
@@ -199,6 +249,13 @@ func TestContains(t *testing.T) {
 		testCase{args: []interface{}{[]interface{}{float64(1.01), float64(1.02)}, float64(1.01)}, expected: true},
 		testCase{args: []interface{}{"abc", "bc"}, expected: true},
 		testCase{args: []interface{}{"abc", "de"}, expected: false},
+		testCase{args: []interface{}{[]int{1, 2}, float64(2)}, expected: true},
+		testCase{args: []interface{}{[]int{1, 2}, float64(3)}, expected: false},
+		testCase{args: []interface{}{[2]string{"a", "b"}, "b"}, expected: true},
+		testCase{args: []interface{}{[]testStatus{"active"}, "active"}, expected: true},
+		testCase{args: []interface{}{[]*string{nil}, "a"}, expected: false},
+		testCase{args: []interface{}{map[string]string{"a": "a"}, "a"}, expected: false},
+		testCase{args: []interface{}{float64(1), float64(1)}, expected: false},
 	}
 
 	for i, c := range cases {
@@ -215,6 +272,12 @@ func BenchmarkContains(b *testing.B) {
 	}
 }
 
+func BenchmarkContainsTyped(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		contains([]int{1, 2}, float64(1))
+	}
+}
+
 func BenchmarkStringContains(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		contains("1", "1")
@@ -246,6 +309,10 @@ func TestNotContains(t *testing.T) {
 		testCase{args: []interface{}{[]interface{}{float64(1.01), float64(1.02)}, float64(1.01)}, expected: false},
 		testCase{args: []interface{}{"abc", "bc"}, expected: false},
 		testCase{args: []interface{}{"abc", "de"}, expected: true},
+		testCase{args: []interface{}{[]int{1, 2}, float64(2)}, expected: false},
+		testCase{args: []interface{}{[]int{1, 2}, float64(3)}, expected: true},
+		testCase{args: []interface{}{[]testStatus{"active"}, "closed"}, expected: true},
+		testCase{args: []interface{}{map[string]string{"a": "a"}, "b"}, expected: false},
 	}
 
 	for i, c := range cases {
@@ -296,6 +363,7 @@ func TestOneOf(t *testing.T) {
 		testCase{args: []interface{}{float64(1), map[interface{}]struct{}{float64(1): struct{}{}, float64(2): struct{}{}}}, expected: true},
 		testCase{args: []interface{}{float64(3), map[interface{}]struct{}{float64(1): struct{}{}, float64(2): struct{}{}}}, expected: false},
 		testCase{args: []interface{}{float64(1.01), map[interface{}]struct{}{1.01: struct{}{}, 1.02: struct{}{}}}, expected: true},
+		testCase{args: []interface{}{map[string]interface{}{"a": float64(1)}, map[interface{}]struct{}{"a": struct{}{}}}, expected: false},
 	}
 	for i, c := range cases {
 		res := oneOf(c.args[0], c.args[1])
@@ -320,6 +388,7 @@ func TestNoneOf(t *testing.T) {
 		testCase{args: []interface{}{float64(3), map[interface{}]struct{}{float64(1): struct{}{}, float64(2): struct{}{}}}, expected: true},
 		testCase{args: []interface{}{float64(1.01), map[interface{}]struct{}{1.01: struct{}{}, 1.02: struct{}{}}}, expected: false},
 		testCase{args: []interface{}{float64(1.03), map[interface{}]struct{}{1.01: struct{}{}, 1.02: struct{}{}}}, expected: true},
+		testCase{args: []interface{}{map[string]interface{}{"a": float64(1)}, map[interface{}]struct{}{"a": struct{}{}}}, expected: true},
 	}
 
 	for i, c := range cases {
@@ -329,3 +398,303 @@ func TestNoneOf(t *testing.T) {
 		}
 	}
 }
+
+type presenceTestCase struct {
+	val      interface{}
+	found    bool
+	expected bool
+}
+
+func TestExists(t *testing.T) {
+	cases := []presenceTestCase{
+		presenceTestCase{val: "a", found: true, expected: true},
+		presenceTestCase{val: nil, found: true, expected: true},
+		presenceTestCase{val: nil, found: false, expected: false},
+	}
+
+	for i, c := range cases {
+		res := exists(c.val, c.found)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestNotExists(t *testing.T) {
+	cases := []presenceTestCase{
+		presenceTestCase{val: "a", found: true, expected: false},
+		presenceTestCase{val: nil, found: true, expected: false},
+		presenceTestCase{val: nil, found: false, expected: true},
+	}
+
+	for i, c := range cases {
+		res := notExists(c.val, c.found)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestIsNull(t *testing.T) {
+	cases := []presenceTestCase{
+		presenceTestCase{val: "a", found: true, expected: false},
+		presenceTestCase{val: nil, found: true, expected: true},
+		presenceTestCase{val: nil, found: false, expected: false},
+	}
+
+	for i, c := range cases {
+		res := isNull(c.val, c.found)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	cases := []presenceTestCase{
+		presenceTestCase{val: nil, found: false, expected: true},
+		presenceTestCase{val: nil, found: true, expected: true},
+		presenceTestCase{val: "", found: true, expected: true},
+		presenceTestCase{val: "a", found: true, expected: false},
+		presenceTestCase{val: []interface{}{}, found: true, expected: true},
+		presenceTestCase{val: []interface{}{"a"}, found: true, expected: false},
+		presenceTestCase{val: []string{}, found: true, expected: true},
+		presenceTestCase{val: []float64{1}, found: true, expected: false},
+		presenceTestCase{val: map[string]interface{}{}, found: true, expected: true},
+		presenceTestCase{val: map[string]interface{}{"a": "b"}, found: true, expected: false},
+		presenceTestCase{val: float64(0), found: true, expected: false},
+	}
+
+	for i, c := range cases {
+		res := isEmpty(c.val, c.found)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestNotEmpty(t *testing.T) {
+	cases := []presenceTestCase{
+		presenceTestCase{val: nil, found: false, expected: false},
+		presenceTestCase{val: nil, found: true, expected: false},
+		presenceTestCase{val: "", found: true, expected: false},
+		presenceTestCase{val: "a", found: true, expected: true},
+		presenceTestCase{val: []interface{}{"a"}, found: true, expected: true},
+		presenceTestCase{val: map[string]interface{}{}, found: true, expected: false},
+	}
+
+	for i, c := range cases {
+		res := notEmpty(c.val, c.found)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func set(vals ...interface{}) map[interface{}]struct{} {
+	m := make(map[interface{}]struct{})
+	for _, v := range vals {
+		m[v] = struct{}{}
+	}
+	return m
+}
+
+func TestContainsAll(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b", "c"}, set("a", "b")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a", "a", "c"}, set("a", "b")}, expected: false},
+		testCase{args: []interface{}{[]string{"a", "b"}, set("a", "b")}, expected: true},
+		testCase{args: []interface{}{[]float64{1, 2}, set(float64(1), float64(3))}, expected: false},
+		testCase{args: []interface{}{[]int{1, 3}, set(float64(1), float64(3))}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a"}, set()}, expected: true},
+		testCase{args: []interface{}{"a", set("a")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{"a"}, "a"}, expected: false},
+		testCase{args: []interface{}{[]interface{}{map[string]interface{}{"a": float64(1)}, "a"}, set("a")}, expected: true},
+		testCase{args: []interface{}{[]map[string]interface{}{map[string]interface{}{"a": float64(1)}}, set("a")}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := containsAll(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestContainsAny(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("b", "c")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("c", "d")}, expected: false},
+		testCase{args: []interface{}{[]string{"a", "b"}, set("b")}, expected: true},
+		testCase{args: []interface{}{[]float64{1, 2}, set(float64(2))}, expected: true},
+		testCase{args: []interface{}{[]interface{}{}, set("a")}, expected: false},
+		testCase{args: []interface{}{"a", set("a")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{map[string]interface{}{"a": float64(1)}}, set("x")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{[]interface{}{"x"}, "x"}, set("x")}, expected: true},
+	}
+
+	for i, c := range cases {
+		res := containsAny(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestContainsNone(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("b", "c")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("c", "d")}, expected: true},
+		testCase{args: []interface{}{[]float64{1, 2}, set(float64(3))}, expected: true},
+		testCase{args: []interface{}{[]interface{}{}, set("a")}, expected: true},
+		testCase{args: []interface{}{"a", set("b")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{map[string]interface{}{"a": float64(1)}}, set("a")}, expected: true},
+	}
+
+	for i, c := range cases {
+		res := containsNone(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestSubsetOf(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("a", "b", "c")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a", "d"}, set("a", "b", "c")}, expected: false},
+		testCase{args: []interface{}{[]string{"a"}, set("a")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{}, set("a")}, expected: true},
+		testCase{args: []interface{}{"a", set("a")}, expected: false},
+		testCase{args: []interface{}{[]interface{}{"a", map[string]interface{}{"a": float64(1)}}, set("a")}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := subsetOf(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestSupersetOf(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b", "c"}, set("a", "b")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a"}, set("a", "b")}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := supersetOf(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{"a", "b"}, set("b", "c")}, expected: true},
+		testCase{args: []interface{}{[]interface{}{"a"}, set("b", "c")}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := intersects(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func BenchmarkContainsAllLong50000(b *testing.B) {
+	var list []interface{}
+	values := make(map[interface{}]struct{})
+
+	// Simulate a list of postal codes
+	for i := 0; i < 50000; i++ {
+		list = append(list, fmt.Sprintf("%d", i))
+		if i%1000 == 0 {
+			values[fmt.Sprintf("%d", i)] = struct{}{}
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		containsAll(list, values)
+	}
+}
+
+func BenchmarkSubsetOfLong50000(b *testing.B) {
+	values := make(map[interface{}]struct{})
+
+	// Simulate a list of postal codes
+	for i := 0; i < 50000; i++ {
+		values[fmt.Sprintf("%d", i)] = struct{}{}
+	}
+	list := []interface{}{"1", "49999"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		subsetOf(list, values)
+	}
+}
+
+// sum is 0.1 + 0.2 calculated at run time, which is not exactly 0.3
+var sum = func(a, b float64) float64 { return a + b }(0.1, 0.2)
+
+func TestEqualWithin(t *testing.T) {
+	cases := []testCase{
+		testCase{args: []interface{}{sum, 0.3}, expected: true},
+		testCase{args: []interface{}{float64(1), 1.0001}, expected: false},
+		testCase{args: []interface{}{"a", "a"}, expected: true},
+		testCase{args: []interface{}{float64(1), "1"}, expected: false},
+		testCase{args: []interface{}{map[string]interface{}{}, map[string]interface{}{}}, expected: false},
+	}
+
+	eq := equalWithin(1e-9)
+	neq := notEqualWithin(1e-9)
+	for i, c := range cases {
+		res := eq(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+		res = neq(c.args[0], c.args[1])
+		if res == c.expected {
+			t.Fatalf("expected not equal case %d to be %v, got %v", i, !c.expected, res)
+		}
+	}
+}
+
+func TestApproxEqual(t *testing.T) {
+	cases := []struct {
+		args     []interface{}
+		options  Options
+		expected bool
+	}{
+		{args: []interface{}{sum, 0.3}, expected: true},
+		{args: []interface{}{float64(1), 1.001}, expected: false},
+		{args: []interface{}{float64(1), 1.001}, options: Options{"epsilon": 0.01}, expected: true},
+		{args: []interface{}{float64(1000), float64(1001)}, options: Options{"relative": 0.01}, expected: true},
+		{args: []interface{}{float64(1), float64(2)}, options: Options{"relative": 0.01}, expected: false},
+		{args: []interface{}{3, float64(3)}, expected: true},
+		{args: []interface{}{"a", "a"}, expected: false},
+	}
+
+	eq := approxEqual(DefaultEpsilon)
+	for i, c := range cases {
+		res := eq(c.args[0], c.args[1], c.options)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+
+	neq := approxNotEqual(DefaultEpsilon)
+	if neq(sum, 0.3, nil) != false {
+		t.Fatal("expected close numbers to not be approx_neq")
+	}
+	if neq(float64(1), float64(2), nil) != true {
+		t.Fatal("expected different numbers to be approx_neq")
+	}
+	if neq("a", "b", nil) != false {
+		t.Fatal("expected strings to not be approx_neq")
+	}
+}
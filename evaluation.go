@@ -0,0 +1,44 @@
+package grules
+
+import (
+	"time"
+)
+
+// evaluation holds everything a rule needs while an engine is being
+// evaluated, it lives for a single call to evaluate
+type evaluation struct {
+	source PropertySource
+	params map[string]interface{}
+	comps  map[string]Comparer
+	now    func() time.Time
+
+	// captures collects the values captured by matching rules, it is nil
+	// unless the caller asked for them
+	captures map[string]string
+
+	// cache holds the values of the paths that several rules refer to, it
+	// is nil if there are none, slots are their places in it, see
+	// Engine.cachePaths
+	cache       []cachedValue
+	slots       map[*path]int
+	cacheHits   int
+	cacheMisses int
+}
+
+// newEvaluation will create a new evaluation of the values in the source
+func newEvaluation(source PropertySource, comps map[string]Comparer) *evaluation {
+	return &evaluation{
+		source: source,
+		comps:  comps,
+		now:    time.Now,
+	}
+}
+
+// with will return a copy of the evaluation for a different source, e.g. the
+// elements of a slice, that shares everything else
+func (ev *evaluation) with(source PropertySource) *evaluation {
+	child := *ev
+	child.source = source
+	child.cache = nil
+	return &child
+}
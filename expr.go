@@ -0,0 +1,445 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// expression is an arithmetic expression over numbers in the props, e.g.
+// "order.total - order.discount" or "max(a.score, b.score) * 2". It can be
+// used in place of a rule's path with "expr", or in place of its value with
+// {"$expr": "..."}. Expressions are parsed and checked when the rule is
+// loaded and evaluated before the comparator.
+type expression struct {
+	src  string
+	root exprNode
+}
+
+// exprNode is a single node in the tree of a parsed expression, eval reports
+// false if the expression has no value, e.g. because a path is missing, is
+// not a number, or there was a division by zero
+type exprNode interface {
+	eval(ev *evaluation) (float64, bool)
+}
+
+// exprFuncs are the functions an expression can call, with the number of
+// arguments they take. -1 means they take one or more.
+var exprFuncs = map[string]int{
+	"abs": 1,
+	"min": -1,
+	"max": -1,
+}
+
+// parseExpression will parse and check the expression in src
+func parseExpression(src string) (*expression, error) {
+	p := exprParser{src: src}
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, fmt.Errorf("expression %q: unexpected %q at %d", src, p.tok.text, p.tok.pos)
+	}
+
+	return &expression{src: src, root: root}, nil
+}
+
+// eval will evaluate the expression against the values of the evaluation
+func (e *expression) eval(ev *evaluation) (float64, bool) {
+	return evalNode(e.root, ev)
+}
+
+// evalNode will evaluate the node n, calling its eval method directly rather
+// than through the interface so that the evaluation does not escape to the
+// heap
+func evalNode(n exprNode, ev *evaluation) (float64, bool) {
+	switch t := n.(type) {
+	case numberNode:
+		return t.eval(ev)
+	case pathNode:
+		return t.eval(ev)
+	case negNode:
+		return t.eval(ev)
+	case binaryNode:
+		return t.eval(ev)
+	case callNode:
+		return t.eval(ev)
+	}
+	return 0, false
+}
+
+// String will return the source of the expression
+func (e *expression) String() string {
+	return e.src
+}
+
+// MarshalJSON will put the expression back into its source form
+func (e *expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.src)
+}
+
+// UnmarshalJSON will parse and check the expression
+func (e *expression) UnmarshalJSON(data []byte) error {
+	var src string
+	err := json.Unmarshal(data, &src)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseExpression(src)
+	if err != nil {
+		return err
+	}
+
+	*e = *parsed
+	return nil
+}
+
+// paths will call fn with every path the expression refers to
+func (e *expression) paths(fn func(p *path)) {
+	var walk func(n exprNode)
+	walk = func(n exprNode) {
+		switch t := n.(type) {
+		case pathNode:
+			fn(t.path)
+		case negNode:
+			walk(t.x)
+		case binaryNode:
+			walk(t.l)
+			walk(t.r)
+		case callNode:
+			for _, arg := range t.args {
+				walk(arg)
+			}
+		}
+	}
+	walk(e.root)
+}
+
+// numberNode is a number literal
+type numberNode float64
+
+func (n numberNode) eval(ev *evaluation) (float64, bool) {
+	return float64(n), true
+}
+
+// pathNode is a number plucked from the props, its path is compiled when the
+// expression is parsed
+type pathNode struct {
+	path *path
+}
+
+func (n pathNode) eval(ev *evaluation) (float64, bool) {
+	val, _ := ev.lookup(n.path)
+	return toFloat64(val)
+}
+
+// negNode is a negated expression
+type negNode struct {
+	x exprNode
+}
+
+func (n negNode) eval(ev *evaluation) (float64, bool) {
+	x, ok := evalNode(n.x, ev)
+	return -x, ok
+}
+
+// binaryNode is an arithmetic operation on two expressions
+type binaryNode struct {
+	op   byte
+	l, r exprNode
+}
+
+func (n binaryNode) eval(ev *evaluation) (float64, bool) {
+	l, ok := evalNode(n.l, ev)
+	if !ok {
+		return 0, false
+	}
+	r, ok := evalNode(n.r, ev)
+	if !ok {
+		return 0, false
+	}
+
+	switch n.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case '%':
+		if r == 0 {
+			return 0, false
+		}
+		return math.Mod(l, r), true
+	}
+
+	return 0, false
+}
+
+// callNode is a call to one of the expression functions
+type callNode struct {
+	fn   string
+	args []exprNode
+}
+
+func (n callNode) eval(ev *evaluation) (float64, bool) {
+	var res float64
+	for i, arg := range n.args {
+		x, ok := evalNode(arg, ev)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case n.fn == "abs":
+			res = math.Abs(x)
+		case i == 0:
+			res = x
+		case n.fn == "min":
+			res = math.Min(res, x)
+		case n.fn == "max":
+			res = math.Max(res, x)
+		}
+	}
+
+	return res, true
+}
+
+// toFloat64 will convert any Go number into a float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case nil:
+		return 0, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+// token is a single lexical token of an expression
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// exprParser is a recursive descent parser for expressions
+type exprParser struct {
+	src string
+	pos int
+	tok token
+}
+
+// next will move the parser to the next token
+func (p *exprParser) next() error {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: p.pos}
+		return nil
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = token{kind: tokenNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = token{kind: tokenIdent, text: p.src[start:p.pos], pos: start}
+	case strings.IndexByte("+-*/%(),", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenOp, text: p.src[start:p.pos], pos: start}
+	default:
+		return fmt.Errorf("expression %q: unexpected %q at %d", p.src, c, start)
+	}
+
+	return nil
+}
+
+// expect will make sure the current token is the operator op and move on
+func (p *exprParser) expect(op string) error {
+	if p.tok.kind != tokenOp || p.tok.text != op {
+		return fmt.Errorf("expression %q: expected %q at %d", p.src, op, p.tok.pos)
+	}
+	return p.next()
+}
+
+// parseSum will parse additions and subtractions
+func (p *exprParser) parseSum() (exprNode, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokenOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryNode{op: op, l: l, r: r}
+	}
+
+	return l, nil
+}
+
+// parseProduct will parse multiplications, divisions and remainders
+func (p *exprParser) parseProduct() (exprNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokenOp && strings.Contains("*/%", p.tok.text) {
+		op := p.tok.text[0]
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryNode{op: op, l: l, r: r}
+	}
+
+	return l, nil
+}
+
+// parseUnary will parse a negation, a number, a path, a function call or an
+// expression in parentheses
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenOp && tok.text == "-":
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x: x}, nil
+	case tok.kind == tokenOp && tok.text == "(":
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case tok.kind == tokenNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q: invalid number %q at %d", p.src, tok.text, tok.pos)
+		}
+		return numberNode(f), p.next()
+	case tok.kind == tokenIdent:
+		err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenOp && p.tok.text == "(" {
+			return p.parseCall(tok)
+		}
+		path, err := compilePath(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q: %v at %d", p.src, err, tok.pos)
+		}
+		return pathNode{path: path}, nil
+	case tok.kind == tokenEOF:
+		return nil, fmt.Errorf("expression %q: unexpected end", p.src)
+	default:
+		return nil, fmt.Errorf("expression %q: unexpected %q at %d", p.src, tok.text, tok.pos)
+	}
+}
+
+// parseCall will parse the arguments of a call to the function named by fn
+// and check that the function exists and takes that many arguments
+func (p *exprParser) parseCall(fn token) (exprNode, error) {
+	arity, ok := exprFuncs[fn.text]
+	if !ok {
+		return nil, fmt.Errorf("expression %q: unknown function %q at %d", p.src, fn.text, fn.pos)
+	}
+
+	err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	var args []exprNode
+	for !(p.tok.kind == tokenOp && p.tok.text == ")") {
+		if len(args) > 0 {
+			err = p.expect(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) == 0 || (arity > 0 && len(args) != arity) {
+		return nil, fmt.Errorf("expression %q: wrong number of arguments to %s at %d", p.src, fn.text, fn.pos)
+	}
+
+	return callNode{fn: fn.text, args: args}, p.next()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,159 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	props := map[string]interface{}{
+		"order": map[string]interface{}{
+			"total":    float64(150),
+			"discount": float64(30),
+			"items":    3,
+		},
+		"a": float64(-4),
+		"s": "text",
+	}
+
+	cases := []struct {
+		src      string
+		expected float64
+		ok       bool
+	}{
+		{src: "1 + 2 * 3", expected: 7, ok: true},
+		{src: "(1 + 2) * 3", expected: 9, ok: true},
+		{src: "10 - 4 - 3", expected: 3, ok: true},
+		{src: "7 % 4", expected: 3, ok: true},
+		{src: "-2 * -3", expected: 6, ok: true},
+		{src: "order.total - order.discount", expected: 120, ok: true},
+		{src: "order.items * 2", expected: 6, ok: true},
+		{src: "abs(a)", expected: 4, ok: true},
+		{src: "min(order.total, order.discount, 100)", expected: 30, ok: true},
+		{src: "max(a, 0.5)", expected: 0.5, ok: true},
+		{src: "order.total / 0", ok: false},
+		{src: "order.total % 0", ok: false},
+		{src: "order.missing + 1", ok: false},
+		{src: "s * 2", ok: false},
+	}
+
+	for i, c := range cases {
+		e, err := parseExpression(c.src)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		res, ok := e.eval(newEvaluation(MapSource(props), nil))
+		if ok != c.ok || res != c.expected {
+			t.Fatalf("expected case %d to be %v, %v, got %v, %v", i, c.expected, c.ok, res, ok)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"pow(2, 3)",
+		"abs(1, 2)",
+		"min()",
+		"1.2.3",
+		"a == b",
+		"a $ b",
+	}
+
+	for _, src := range cases {
+		_, err := parseExpression(src)
+		if err == nil {
+			t.Fatalf("expected %q to fail to parse", src)
+		}
+	}
+}
+
+func TestRule_evaluateExpression(t *testing.T) {
+	props := map[string]interface{}{
+		"order": map[string]interface{}{
+			"total":    float64(150),
+			"discount": float64(30),
+		},
+		"items_count": float64(4),
+		"limit":       float64(8),
+	}
+
+	cases := []struct {
+		name     string
+		json     string
+		expected bool
+	}{
+		{name: "expr", json: `{"comparator":"gt","expr":"order.total - order.discount","value":100}`, expected: true},
+		{name: "expr, false", json: `{"comparator":"gt","expr":"order.total - order.discount","value":120}`, expected: false},
+		{name: "expr value", json: `{"comparator":"gte","path":"limit","value":{"$expr":"items_count * 2"}}`, expected: true},
+		{name: "expr and expr value", json: `{"comparator":"eq","expr":"items_count * 2","value":{"$expr":"limit"}}`, expected: true},
+		{name: "missing", json: `{"comparator":"gt","expr":"order.tax + 1","value":0}`, expected: false},
+		{name: "presence", json: `{"comparator":"notexists","expr":"order.tax + 1"}`, expected: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r rule
+			err := json.Unmarshal([]byte(c.json), &r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+			if res != c.expected {
+				t.Fatalf("expected rule to be %v, got %v", c.expected, res)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []string{
+			`{"comparator":"gt","expr":"order.total -","value":100}`,
+			`{"comparator":"gt","path":"order.total","value":{"$expr":"sqrt(2)"}}`,
+		}
+		for i, j := range invalid {
+			var r rule
+			err := json.Unmarshal([]byte(j), &r)
+			if err == nil {
+				t.Fatalf("expected case %d to fail to load", i)
+			}
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"gt","expr":"order.total - order.discount","value":{"$expr":"limit * 2"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func BenchmarkExpression(b *testing.B) {
+	e, err := parseExpression("order.total - order.discount * 2")
+	if err != nil {
+		b.Fatal(err)
+	}
+	props := map[string]interface{}{
+		"order": map[string]interface{}{
+			"total":    float64(150),
+			"discount": float64(30),
+		},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.eval(newEvaluation(MapSource(props), nil))
+	}
+}
@@ -0,0 +1,426 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// fuzzyValue is the value of a fuzzy string matching rule, a list of
+// candidates and the threshold they need to meet. Candidates are lower cased
+// and, for the phonetic comparators, encoded when the rule is loaded.
+type fuzzyValue struct {
+	values    []string
+	threshold float64
+	raw       interface{}
+}
+
+// MarshalJSON will put the fuzzy value back into its original form
+func (f *fuzzyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.raw)
+}
+
+// parseFuzzy will return a value parser for a fuzzy comparator. The value can
+// be a string, a list of strings or an object like
+// {"values": ["Jon", "John"], "threshold": 2}. The threshold is required if
+// withThreshold is true, encode is applied to each of the candidates.
+func parseFuzzy(name string, withThreshold bool, encode func(string) string) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		f := fuzzyValue{raw: v}
+
+		values := v
+		if m, ok := v.(map[string]interface{}); ok {
+			values = m["values"]
+			threshold, ok := m["threshold"].(float64)
+			if withThreshold && !ok {
+				return nil, fmt.Errorf("%s value must have a numeric threshold", name)
+			}
+			f.threshold = threshold
+		} else if withThreshold {
+			return nil, fmt.Errorf("%s value must be an object with values and a threshold", name)
+		}
+
+		switch t := values.(type) {
+		case string:
+			f.values = []string{encode(t)}
+		case []interface{}:
+			for _, c := range t {
+				s, ok := c.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s values must be strings", name)
+				}
+				f.values = append(f.values, encode(s))
+			}
+		default:
+			return nil, fmt.Errorf("%s values must be a string or a list of strings", name)
+		}
+
+		return &f, nil
+	}
+}
+
+// levenshteinLessThanEqual will return true if the edit distance between a
+// and any of the candidates in b is at most the threshold
+func levenshteinLessThanEqual(a, b interface{}) bool {
+	at, ok := a.(string)
+	if !ok {
+		return false
+	}
+	f, ok := b.(*fuzzyValue)
+	if !ok {
+		return false
+	}
+
+	at = strings.ToLower(at)
+	for _, v := range f.values {
+		if float64(levenshtein(at, v)) <= f.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// similarityGreaterThanEqual will return true if the Jaro-Winkler similarity
+// between a and any of the candidates in b is at least the threshold
+func similarityGreaterThanEqual(a, b interface{}) bool {
+	at, ok := a.(string)
+	if !ok {
+		return false
+	}
+	f, ok := b.(*fuzzyValue)
+	if !ok {
+		return false
+	}
+
+	at = strings.ToLower(at)
+	for _, v := range f.values {
+		if jaroWinkler(at, v) >= f.threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// soundexEqual will return true if a sounds like any of the candidates in b
+// according to American Soundex
+func soundexEqual(a, b interface{}) bool {
+	return phoneticEqual(a, b, soundex)
+}
+
+// metaphoneEqual will return true if a sounds like any of the candidates in b
+// according to Metaphone
+func metaphoneEqual(a, b interface{}) bool {
+	return phoneticEqual(a, b, metaphone)
+}
+
+// phoneticEqual will return true if the encoding of a matches any of the
+// already encoded candidates in b
+func phoneticEqual(a, b interface{}, encode func(string) string) bool {
+	at, ok := a.(string)
+	if !ok {
+		return false
+	}
+	f, ok := b.(*fuzzyValue)
+	if !ok {
+		return false
+	}
+
+	code := encode(at)
+	if code == "" {
+		return false
+	}
+	for _, v := range f.values {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein will return the number of single rune insertions, deletions
+// and substitutions needed to turn a into b
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	if len(ar) == 0 {
+		return len(br)
+	}
+	if len(br) == 0 {
+		return len(ar)
+	}
+
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(br)]
+}
+
+// jaroWinkler will return the Jaro-Winkler similarity of a and b, 1 being an
+// exact match and 0 being no similarity at all
+func jaroWinkler(a, b string) float64 {
+	ar, br := []rune(a), []rune(b)
+	if len(ar) == 0 && len(br) == 0 {
+		return 1
+	}
+	if len(ar) == 0 || len(br) == 0 {
+		return 0
+	}
+
+	window := max(len(ar), len(br))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	aMatched := make([]bool, len(ar))
+	bMatched := make([]bool, len(br))
+	var matches int
+	for i := range ar {
+		lo := max(0, i-window)
+		hi := min(len(br), i+window+1)
+		for j := lo; j < hi; j++ {
+			if bMatched[j] || ar[i] != br[j] {
+				continue
+			}
+			aMatched[i], bMatched[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	var transpositions, k int
+	for i := range ar {
+		if !aMatched[i] {
+			continue
+		}
+		for !bMatched[k] {
+			k++
+		}
+		if ar[i] != br[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ar)) + m/float64(len(br)) + (m-float64(transpositions)/2)/m) / 3
+
+	// Reward a common prefix of up to 4 runes
+	var prefix int
+	for prefix < min(4, len(ar), len(br)) && ar[prefix] == br[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// letters will return the ASCII letters of s in upper case, phonetic
+// encodings ignore everything else
+func letters(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		r = unicode.ToUpper(r)
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// soundexCodes is the American Soundex digit for each letter, vowels and Y
+// are 0 and H and W are left out because they are ignored
+var soundexCodes = map[byte]byte{
+	'A': '0', 'E': '0', 'I': '0', 'O': '0', 'U': '0', 'Y': '0',
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// soundex will return the American Soundex code of s, e.g. Robert is R163
+func soundex(s string) string {
+	w := letters(s)
+	if w == "" {
+		return ""
+	}
+
+	code := []byte{w[0]}
+	last := soundexCodes[w[0]]
+	for i := 1; i < len(w) && len(code) < 4; i++ {
+		c, ok := soundexCodes[w[i]]
+		if !ok {
+			// H and W do not separate letters with the same code
+			continue
+		}
+		if c != '0' && c != last {
+			code = append(code, c)
+		}
+		last = c
+	}
+
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// metaphone will return the Metaphone code of s as described by Lawrence
+// Philips, e.g. Catherine and Kathryn are both K0RN
+func metaphone(s string) string {
+	w := letters(s)
+	if w == "" {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(w, "AE"), strings.HasPrefix(w, "GN"), strings.HasPrefix(w, "KN"),
+		strings.HasPrefix(w, "PN"), strings.HasPrefix(w, "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w = "S" + w[1:]
+	case strings.HasPrefix(w, "WH"):
+		w = "W" + w[2:]
+	}
+
+	n := len(w)
+	at := func(i int) byte {
+		if i < 0 || i >= n {
+			return 0
+		}
+		return w[i]
+	}
+	isVowel := func(c byte) bool {
+		return c != 0 && strings.IndexByte("AEIOU", c) >= 0
+	}
+	isFrontVowel := func(c byte) bool {
+		return c != 0 && strings.IndexByte("EIY", c) >= 0
+	}
+
+	var code strings.Builder
+	for i := 0; i < n; i++ {
+		c := w[i]
+
+		// Doubled letters only count once, except for C
+		if c != 'C' && at(i-1) == c {
+			continue
+		}
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				code.WriteByte(c)
+			}
+		case 'B':
+			// Silent at the end after M, as in dumb
+			if !(i == n-1 && at(i-1) == 'M') {
+				code.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case at(i-1) == 'S' && isFrontVowel(at(i+1)):
+				// Silent in SCI, SCE and SCY
+			case at(i+1) == 'I' && at(i+2) == 'A':
+				code.WriteByte('X')
+			case isFrontVowel(at(i + 1)):
+				code.WriteByte('S')
+			case at(i-1) == 'S' && at(i+1) == 'H':
+				code.WriteByte('K')
+			case at(i+1) == 'H':
+				if i == 0 && isVowel(at(i+2)) {
+					code.WriteByte('K')
+				} else {
+					code.WriteByte('X')
+				}
+			default:
+				code.WriteByte('K')
+			}
+		case 'D':
+			if at(i+1) == 'G' && isFrontVowel(at(i+2)) {
+				code.WriteByte('J')
+				i += 2
+			} else {
+				code.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case at(i+1) == 'H' && i+2 < n && !isVowel(at(i+2)):
+				// Silent in GH when not at the end or before a vowel
+			case at(i+1) == 'N' && (i+2 == n || (i+4 == n && at(i+2) == 'E' && at(i+3) == 'D')):
+				// Silent in GN and GNED at the end
+			case isFrontVowel(at(i+1)) && at(i-1) != 'G':
+				code.WriteByte('J')
+			default:
+				code.WriteByte('K')
+			}
+		case 'H':
+			if i < n-1 && strings.IndexByte("CSPTG", at(i-1)) < 0 && isVowel(at(i+1)) {
+				code.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				code.WriteByte('K')
+			}
+		case 'P':
+			if at(i+1) == 'H' {
+				code.WriteByte('F')
+			} else {
+				code.WriteByte('P')
+			}
+		case 'Q':
+			code.WriteByte('K')
+		case 'S':
+			switch {
+			case at(i+1) == 'H':
+				code.WriteByte('X')
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code.WriteByte('X')
+			default:
+				code.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code.WriteByte('X')
+			case at(i+1) == 'H':
+				code.WriteByte('0')
+			case at(i+1) == 'C' && at(i+2) == 'H':
+				// Silent in TCH
+			default:
+				code.WriteByte('T')
+			}
+		case 'V':
+			code.WriteByte('F')
+		case 'W', 'Y':
+			if isVowel(at(i + 1)) {
+				code.WriteByte(c)
+			}
+		case 'X':
+			code.WriteString("KS")
+		case 'Z':
+			code.WriteByte('S')
+		default:
+			code.WriteByte(c)
+		}
+	}
+
+	return code.String()
+}
@@ -0,0 +1,226 @@
+package grules
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "abc", b: "", expected: 3},
+		{a: "kitten", b: "sitting", expected: 3},
+		{a: "flaw", b: "lawn", expected: 2},
+		{a: "jon", b: "john", expected: 1},
+		{a: "müller", b: "muller", expected: 1},
+	}
+
+	for i, c := range cases {
+		res := levenshtein(c.a, c.b)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected float64
+	}{
+		{a: "", b: "", expected: 1},
+		{a: "abc", b: "", expected: 0},
+		{a: "martha", b: "marhta", expected: 0.9611},
+		{a: "dwayne", b: "duane", expected: 0.84},
+		{a: "dixon", b: "dicksonx", expected: 0.8133},
+		{a: "abc", b: "xyz", expected: 0},
+	}
+
+	for i, c := range cases {
+		res := jaroWinkler(c.a, c.b)
+		if math.Abs(res-c.expected) > 0.0001 {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	cases := map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Lee":      "L000",
+		"":         "",
+	}
+
+	for in, expected := range cases {
+		res := soundex(in)
+		if res != expected {
+			t.Fatalf("expected %q to be %v, got %v", in, expected, res)
+		}
+	}
+}
+
+func TestMetaphone(t *testing.T) {
+	cases := map[string]string{
+		"Catherine": "K0RN",
+		"Kathryn":   "K0RN",
+		"Smith":     "SM0",
+		"Smyth":     "SM0",
+		"Knight":    "NT",
+		"Thumb":     "0M",
+		"Xavier":    "SFR",
+		"Phillips":  "FLPS",
+		"":          "",
+	}
+
+	for in, expected := range cases {
+		res := metaphone(in)
+		if res != expected {
+			t.Fatalf("expected %q to be %v, got %v", in, expected, res)
+		}
+	}
+}
+
+func TestLevenshteinLessThanEqual(t *testing.T) {
+	names, err := parseFuzzy("levenshtein_lte", true, strings.ToLower)(map[string]interface{}{"values": []interface{}{"John Smith", "Jane Doe"}, "threshold": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		testCase{args: []interface{}{"John Smith", names}, expected: true},
+		testCase{args: []interface{}{"jon smyth", names}, expected: true},
+		testCase{args: []interface{}{"Jane Do", names}, expected: true},
+		testCase{args: []interface{}{"Jim Beam", names}, expected: false},
+		testCase{args: []interface{}{float64(1), names}, expected: false},
+		testCase{args: []interface{}{"John Smith", "John Smith"}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := levenshteinLessThanEqual(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestSimilarityGreaterThanEqual(t *testing.T) {
+	names, err := parseFuzzy("similarity_gte", true, strings.ToLower)(map[string]interface{}{"values": "Martha", "threshold": 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		testCase{args: []interface{}{"MARHTA", names}, expected: true},
+		testCase{args: []interface{}{"Marta", names}, expected: true},
+		testCase{args: []interface{}{"Mary", names}, expected: false},
+		testCase{args: []interface{}{nil, names}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := similarityGreaterThanEqual(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestPhoneticEqual(t *testing.T) {
+	soundexNames, err := parseFuzzy("soundex_eq", false, soundex)([]interface{}{"Robert", "Ashcraft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metaphoneNames, err := parseFuzzy("metaphone_eq", false, metaphone)("Catherine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		testCase{args: []interface{}{"Rupert", soundexNames}, expected: true},
+		testCase{args: []interface{}{"Ashcroft", soundexNames}, expected: true},
+		testCase{args: []interface{}{"Rubin", soundexNames}, expected: false},
+		testCase{args: []interface{}{"", soundexNames}, expected: false},
+	}
+	for i, c := range cases {
+		res := soundexEqual(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected soundex case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+
+	cases = []testCase{
+		testCase{args: []interface{}{"Kathryn", metaphoneNames}, expected: true},
+		testCase{args: []interface{}{"Karen", metaphoneNames}, expected: false},
+		testCase{args: []interface{}{float64(1), metaphoneNames}, expected: false},
+	}
+	for i, c := range cases {
+		res := metaphoneEqual(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected metaphone case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestParseFuzzy(t *testing.T) {
+	invalid := []string{
+		`{"comparator":"levenshtein_lte","path":"name","value":"John"}`,
+		`{"comparator":"levenshtein_lte","path":"name","value":{"values":["John"]}}`,
+		`{"comparator":"similarity_gte","path":"name","value":{"values":[1],"threshold":0.9}}`,
+		`{"comparator":"soundex_eq","path":"name","value":1}`,
+	}
+
+	for i, j := range invalid {
+		var r rule
+		err := json.Unmarshal([]byte(j), &r)
+		if err == nil {
+			t.Fatalf("expected case %d to fail to load", i)
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"or","rules":[{"comparator":"levenshtein_lte","path":"name","value":{"threshold":2,"values":["John"]}},{"comparator":"soundex_eq","path":"name","value":["Robert","Rupert"]}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func BenchmarkLevenshteinLessThanEqual(b *testing.B) {
+	names := &fuzzyValue{values: []string{"john smith", "jane doe"}, threshold: 2}
+	for i := 0; i < b.N; i++ {
+		levenshteinLessThanEqual("Jon Smyth", names)
+	}
+}
+
+func BenchmarkSimilarityGreaterThanEqual(b *testing.B) {
+	names := &fuzzyValue{values: []string{"john smith", "jane doe"}, threshold: 0.9}
+	for i := 0; i < b.N; i++ {
+		similarityGreaterThanEqual("Jon Smyth", names)
+	}
+}
+
+func BenchmarkMetaphoneEqual(b *testing.B) {
+	names := &fuzzyValue{values: []string{metaphone("Catherine")}}
+	for i := 0; i < b.N; i++ {
+		metaphoneEqual("Kathryn", names)
+	}
+}
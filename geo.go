@@ -0,0 +1,305 @@
+package grules
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+)
+
+// earthRadius is the mean radius of the earth in kilometers
+const earthRadius = 6371.0088
+
+// point is a location given in degrees
+type point struct {
+	Lat float64
+	Lon float64
+}
+
+// circle is the value of a within_radius rule, the radius is in kilometers
+type circle struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Radius float64 `json:"radius"`
+}
+
+// bbox is the bounding box of a polygon, it lets us skip the point in
+// polygon test for points that are nowhere near
+type bbox struct {
+	minLon, minLat, maxLon, maxLat float64
+}
+
+// contains will return true if the point is inside the bounding box
+func (b bbox) contains(p point) bool {
+	return p.Lon >= b.minLon && p.Lon <= b.maxLon && p.Lat >= b.minLat && p.Lat <= b.maxLat
+}
+
+// ring is a closed line of points, the first and last points are equal
+type ring []point
+
+// polygon is the value of a within_polygon rule. It is one or more polygons,
+// each with an outer ring followed by any number of holes, the raw GeoJSON is
+// kept so that the rule can be marshaled back into its original form.
+type polygon struct {
+	polygons []polygonRings
+	raw      interface{}
+}
+
+// polygonRings is a single GeoJSON polygon with its bounding box
+type polygonRings struct {
+	rings []ring
+	box   bbox
+}
+
+// MarshalJSON will put the polygon back into its GeoJSON form
+func (p *polygon) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.raw)
+}
+
+// parseCircle will parse the value of a within_radius rule when it is loaded
+func parseCircle(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("within_radius value must be an object with lat, lon and radius")
+	}
+
+	var c circle
+	var okLat, okLon, okRadius bool
+	c.Lat, okLat = m["lat"].(float64)
+	c.Lon, okLon = m["lon"].(float64)
+	c.Radius, okRadius = m["radius"].(float64)
+	if !okLat || !okLon || !okRadius {
+		return nil, errors.New("within_radius value must be an object with lat, lon and radius")
+	}
+	if c.Radius < 0 {
+		return nil, errors.New("within_radius radius must not be negative")
+	}
+
+	return &c, nil
+}
+
+// parsePolygon will parse a GeoJSON Polygon or MultiPolygon when the rule is
+// loaded and compute the bounding box of each polygon
+func parsePolygon(v interface{}) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("within_polygon value must be a GeoJSON polygon")
+	}
+
+	coords, ok := m["coordinates"].([]interface{})
+	if !ok {
+		return nil, errors.New("within_polygon value must have coordinates")
+	}
+
+	p := polygon{raw: v}
+	switch m["type"] {
+	case "Polygon":
+		pr, err := parsePolygonRings(coords)
+		if err != nil {
+			return nil, err
+		}
+		p.polygons = append(p.polygons, pr)
+	case "MultiPolygon":
+		for _, c := range coords {
+			rings, ok := c.([]interface{})
+			if !ok {
+				return nil, errors.New("within_polygon MultiPolygon must be a list of polygons")
+			}
+			pr, err := parsePolygonRings(rings)
+			if err != nil {
+				return nil, err
+			}
+			p.polygons = append(p.polygons, pr)
+		}
+	default:
+		return nil, errors.New("within_polygon type must be Polygon or MultiPolygon")
+	}
+
+	return &p, nil
+}
+
+// parsePolygonRings will parse the rings of a single GeoJSON polygon
+func parsePolygonRings(coords []interface{}) (polygonRings, error) {
+	if len(coords) == 0 {
+		return polygonRings{}, errors.New("within_polygon polygon must have an outer ring")
+	}
+
+	pr := polygonRings{
+		box: bbox{
+			minLon: math.Inf(1),
+			minLat: math.Inf(1),
+			maxLon: math.Inf(-1),
+			maxLat: math.Inf(-1),
+		},
+	}
+	for i, c := range coords {
+		positions, ok := c.([]interface{})
+		if !ok || len(positions) < 4 {
+			return polygonRings{}, errors.New("within_polygon ring must have at least 4 positions")
+		}
+
+		var r ring
+		for _, pos := range positions {
+			pt, ok := toPoint(pos)
+			if !ok {
+				return polygonRings{}, errors.New("within_polygon position must be [lon, lat]")
+			}
+			r = append(r, pt)
+
+			// Only the outer ring can widen the bounding box
+			if i == 0 {
+				pr.box.minLon = math.Min(pr.box.minLon, pt.Lon)
+				pr.box.minLat = math.Min(pr.box.minLat, pt.Lat)
+				pr.box.maxLon = math.Max(pr.box.maxLon, pt.Lon)
+				pr.box.maxLat = math.Max(pr.box.maxLat, pt.Lat)
+			}
+		}
+		pr.rings = append(pr.rings, r)
+	}
+
+	return pr, nil
+}
+
+// toPoint will convert a prop into a point. Props can be a map with lat and
+// lon, or a GeoJSON style [lon, lat] array, of any type, or a struct with lat
+// and lon fields.
+func toPoint(v interface{}) (point, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		lat, ok := t["lat"].(float64)
+		if !ok {
+			return point{}, false
+		}
+		lon, ok := t["lon"].(float64)
+		if !ok {
+			return point{}, false
+		}
+		return point{Lat: lat, Lon: lon}, true
+	case []interface{}:
+		if len(t) < 2 {
+			return point{}, false
+		}
+		lon, ok := t[0].(float64)
+		if !ok {
+			return point{}, false
+		}
+		lat, ok := t[1].(float64)
+		if !ok {
+			return point{}, false
+		}
+		return point{Lat: lat, Lon: lon}, true
+	case []float64:
+		if len(t) < 2 {
+			return point{}, false
+		}
+		return point{Lat: t[1], Lon: t[0]}, true
+	case nil:
+		return point{}, false
+	}
+
+	// Maps, slices and structs of other types are walked with reflection
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	latKey, lonKey := "lat", "lon"
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		latKey, lonKey = "1", "0"
+	case reflect.Map, reflect.Struct:
+	default:
+		return point{}, false
+	}
+
+	lat, ok := member(rv, latKey)
+	if !ok {
+		return point{}, false
+	}
+	lon, ok := member(rv, lonKey)
+	if !ok {
+		return point{}, false
+	}
+	var pt point
+	if pt.Lat, ok = toFloat64(lat); !ok {
+		return point{}, false
+	}
+	if pt.Lon, ok = toFloat64(lon); !ok {
+		return point{}, false
+	}
+	return pt, true
+}
+
+// haversine will return the great circle distance between two points in
+// kilometers
+func haversine(a, b point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// contains will return true if the point is inside the ring, using the
+// even-odd ray casting rule
+func (r ring) contains(p point) bool {
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			in = !in
+		}
+	}
+	return in
+}
+
+// contains will return true if the point is inside the outer ring of the
+// polygon and not inside any of its holes
+func (pr polygonRings) contains(p point) bool {
+	if !pr.box.contains(p) || !pr.rings[0].contains(p) {
+		return false
+	}
+	for _, hole := range pr.rings[1:] {
+		if hole.contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// withinRadius will return true if the point a is within the circle b
+func withinRadius(a, b interface{}) bool {
+	c, ok := b.(*circle)
+	if !ok {
+		return false
+	}
+
+	p, ok := toPoint(a)
+	if !ok {
+		return false
+	}
+
+	return haversine(p, point{Lat: c.Lat, Lon: c.Lon}) <= c.Radius
+}
+
+// withinPolygon will return true if the point a is within the polygon b
+func withinPolygon(a, b interface{}) bool {
+	poly, ok := b.(*polygon)
+	if !ok {
+		return false
+	}
+
+	p, ok := toPoint(a)
+	if !ok {
+		return false
+	}
+
+	for _, pr := range poly.polygons {
+		if pr.contains(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package grules
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+	london := point{Lat: 51.5074, Lon: -0.1278}
+	paris := point{Lat: 48.8566, Lon: 2.3522}
+
+	d := haversine(london, paris)
+	if math.Abs(d-343.5) > 1 {
+		t.Fatalf("expected distance to be about 343.5km, got %v", d)
+	}
+
+	if haversine(london, london) != 0 {
+		t.Fatal("expected distance to self to be 0")
+	}
+}
+
+type testPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func TestWithinRadius(t *testing.T) {
+	atlanta, err := parseCircle(map[string]interface{}{"lat": 33.749, "lon": -84.388, "radius": float64(15)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		testCase{args: []interface{}{map[string]interface{}{"lat": 33.7748, "lon": -84.2963}, atlanta}, expected: true},
+		testCase{args: []interface{}{[]interface{}{-84.2963, 33.7748}, atlanta}, expected: true},
+		testCase{args: []interface{}{[]float64{-84.2963, 33.7748}, atlanta}, expected: true},
+		testCase{args: []interface{}{map[string]interface{}{"lat": 34.0007, "lon": -81.0348}, atlanta}, expected: false},
+		testCase{args: []interface{}{map[string]interface{}{"lat": "33.7748", "lon": -84.2963}, atlanta}, expected: false},
+		testCase{args: []interface{}{"atlanta", atlanta}, expected: false},
+		testCase{args: []interface{}{map[string]interface{}{"lat": 33.7748, "lon": -84.2963}, "atlanta"}, expected: false},
+		testCase{args: []interface{}{map[string]float64{"lat": 33.7748, "lon": -84.2963}, atlanta}, expected: true},
+		testCase{args: []interface{}{map[string]string{"lat": "33.7748", "lon": "-84.2963"}, atlanta}, expected: false},
+		testCase{args: []interface{}{[]float32{-84.2963, 33.7748}, atlanta}, expected: true},
+		testCase{args: []interface{}{[]int{-84, 34}, atlanta}, expected: false},
+		testCase{args: []interface{}{[1]int{-84}, atlanta}, expected: false},
+		testCase{args: []interface{}{testPoint{Lat: 33.7748, Lon: -84.2963}, atlanta}, expected: true},
+		testCase{args: []interface{}{&testPoint{Lat: 34.0007, Lon: -81.0348}, atlanta}, expected: false},
+		testCase{args: []interface{}{(*testPoint)(nil), atlanta}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := withinRadius(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestWithinPolygon(t *testing.T) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]}`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	square, err := parsePolygon(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal([]byte(`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]],[[[20,20],[21,20],[21,21],[20,21],[20,20]]]]}`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, err := parsePolygon(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []testCase{
+		testCase{args: []interface{}{[]interface{}{float64(2), float64(2)}, square}, expected: true},
+		testCase{args: []interface{}{map[string]interface{}{"lat": float64(8), "lon": float64(1)}, square}, expected: true},
+		testCase{args: []interface{}{[]interface{}{float64(5), float64(5)}, square}, expected: false},
+		testCase{args: []interface{}{[]interface{}{float64(11), float64(5)}, square}, expected: false},
+		testCase{args: []interface{}{[]interface{}{20.5, 20.5}, multi}, expected: true},
+		testCase{args: []interface{}{[]interface{}{float64(10), float64(10)}, multi}, expected: false},
+		testCase{args: []interface{}{"here", square}, expected: false},
+		testCase{args: []interface{}{[]int{2, 2}, square}, expected: true},
+		testCase{args: []interface{}{map[string]int{"lat": 5, "lon": 5}, square}, expected: false},
+	}
+
+	for i, c := range cases {
+		res := withinPolygon(c.args[0], c.args[1])
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestParseGeoValues(t *testing.T) {
+	invalid := []string{
+		`{"composites":[{"operator":"and","rules":[{"comparator":"within_radius","path":"loc","value":[1,2]}]}]}`,
+		`{"composites":[{"operator":"and","rules":[{"comparator":"within_radius","path":"loc","value":{"lat":1,"lon":2}}]}]}`,
+		`{"composites":[{"operator":"and","rules":[{"comparator":"within_polygon","path":"loc","value":{"type":"Point","coordinates":[1,2]}}]}]}`,
+		`{"composites":[{"operator":"and","rules":[{"comparator":"within_polygon","path":"loc","value":{"type":"Polygon","coordinates":[[[0,0],[1,1],[0,0]]]}}]}]}`,
+	}
+
+	for i, j := range invalid {
+		_, err := NewJSONEngine(json.RawMessage(j))
+		if err == nil {
+			t.Fatalf("expected case %d to fail to load", i)
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"within_radius","path":"loc","value":{"lat":1,"lon":2,"radius":3}},{"comparator":"within_polygon","path":"loc","value":{"coordinates":[[[0,0],[1,0],[1,1],[0,0]]],"type":"Polygon"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func BenchmarkWithinRadius(b *testing.B) {
+	c := &circle{Lat: 33.749, Lon: -84.388, Radius: 15}
+	p := map[string]interface{}{"lat": 33.7748, "lon": -84.2963}
+
+	for i := 0; i < b.N; i++ {
+		withinRadius(p, c)
+	}
+}
+
+func BenchmarkWithinPolygon(b *testing.B) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]]]}`), &raw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	poly, err := parsePolygon(raw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := []interface{}{float64(2), float64(2)}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		withinPolygon(p, poly)
+	}
+}
@@ -0,0 +1,221 @@
+package grules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// ArityAny means the comparator accepts any value, or that it is not
+	// known what it accepts
+	ArityAny = iota
+	// ArityNone means the comparator ignores the rule's value
+	ArityNone
+	// ArityOne means the comparator expects a single value
+	ArityOne
+	// ArityList means the comparator expects a list of values
+	ArityList
+)
+
+// ComparatorInfo describes a comparator so that tools, like a rule editor,
+// can list the comparators of an engine and check the rules that use them.
+// PropTypes are the types of the value at the path that the comparator can
+// be true for, empty means any type. ValueType is the type of the rule's
+// value, or of each of its elements if Arity is ArityList, empty means any
+// type. Negation is the comparator that is true when this one is false.
+type ComparatorInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	PropTypes   []string `json:"prop_types,omitempty"`
+	ValueType   string   `json:"value_type,omitempty"`
+	Arity       int      `json:"arity"`
+	Negation    string   `json:"negation,omitempty"`
+}
+
+var (
+	scalarTypes = []string{TypeString, TypeNumber, TypeBool}
+	orderTypes  = []string{TypeString, TypeNumber}
+	listTypes   = []string{TypeArray}
+	stringTypes = []string{TypeString}
+	numberTypes = []string{TypeNumber}
+	pointTypes  = []string{TypeObject, TypeArray}
+	timeTypes   = []string{TypeString, TypeNumber}
+)
+
+// defaultInfos describe all of the default comparators
+var defaultInfos = map[string]ComparatorInfo{
+	"eq":        {Description: "a == b", PropTypes: scalarTypes, Arity: ArityOne, Negation: "neq"},
+	"neq":       {Description: "a != b", PropTypes: scalarTypes, Arity: ArityOne, Negation: "eq"},
+	"gt":        {Description: "a > b", PropTypes: orderTypes, Arity: ArityOne, Negation: "lte"},
+	"gte":       {Description: "a >= b", PropTypes: orderTypes, Arity: ArityOne, Negation: "lt"},
+	"lt":        {Description: "a < b", PropTypes: orderTypes, Arity: ArityOne, Negation: "gte"},
+	"lte":       {Description: "a <= b", PropTypes: orderTypes, Arity: ArityOne, Negation: "gt"},
+	"contains":  {Description: "a contains b", PropTypes: []string{TypeString, TypeArray}, Arity: ArityOne, Negation: "ncontains"},
+	"ncontains": {Description: "a does not contain b", PropTypes: []string{TypeString, TypeArray}, Arity: ArityOne, Negation: "contains"},
+	"oneof":     {Description: "a is one of b", PropTypes: scalarTypes, Arity: ArityList, Negation: "noneof"},
+	"noneof":    {Description: "a is not one of b", PropTypes: scalarTypes, Arity: ArityList, Negation: "oneof"},
+	"regex":     {Description: "a matches the regular expression b", PropTypes: stringTypes, ValueType: TypeString, Arity: ArityOne},
+
+	"containsall":  {Description: "a contains every item in b", PropTypes: listTypes, Arity: ArityList},
+	"containsany":  {Description: "a contains at least one item in b", PropTypes: listTypes, Arity: ArityList, Negation: "containsnone"},
+	"containsnone": {Description: "a contains no item in b", PropTypes: listTypes, Arity: ArityList, Negation: "containsany"},
+	"subsetof":     {Description: "every item in a is in b", PropTypes: listTypes, Arity: ArityList},
+	"supersetof":   {Description: "a contains every item in b", PropTypes: listTypes, Arity: ArityList},
+	"intersects":   {Description: "a and b share at least one item", PropTypes: listTypes, Arity: ArityList, Negation: "containsnone"},
+
+	"within_radius":  {Description: "the point a is within b.radius kilometers of b.lat, b.lon", PropTypes: pointTypes, ValueType: TypeObject, Arity: ArityOne},
+	"within_polygon": {Description: "the point a is inside the GeoJSON polygon b", PropTypes: pointTypes, ValueType: TypeObject, Arity: ArityOne},
+
+	"levenshtein_lte": {Description: "the edit distance between a and any of b.values is at most b.threshold", PropTypes: stringTypes, ValueType: TypeObject, Arity: ArityOne},
+	"similarity_gte":  {Description: "the Jaro-Winkler similarity between a and any of b.values is at least b.threshold", PropTypes: stringTypes, ValueType: TypeObject, Arity: ArityOne},
+	"soundex_eq":      {Description: "a has the same Soundex code as any of b", PropTypes: stringTypes, ValueType: TypeString},
+	"metaphone_eq":    {Description: "a has the same Metaphone code as any of b", PropTypes: stringTypes, ValueType: TypeString},
+
+	"is_string": {Description: "a is a string", Arity: ArityNone},
+	"is_number": {Description: "a is a number", Arity: ArityNone},
+	"is_bool":   {Description: "a is a boolean", Arity: ArityNone},
+	"is_array":  {Description: "a is an array", Arity: ArityNone},
+	"is_object": {Description: "a is an object", Arity: ArityNone},
+	"is_type":   {Description: "a is of the type b", ValueType: TypeString, Arity: ArityOne},
+
+	"approx_eq":  {Description: "the numbers a and b are within a tolerance", PropTypes: numberTypes, ValueType: TypeNumber, Arity: ArityOne, Negation: "approx_neq"},
+	"approx_neq": {Description: "the numbers a and b are not within a tolerance", PropTypes: numberTypes, ValueType: TypeNumber, Arity: ArityOne, Negation: "approx_eq"},
+	"bucket":     {Description: "a hashes into the percentage or range b", PropTypes: orderTypes, Arity: ArityOne},
+
+	"weekday":      {Description: "the time a falls on one of b.days", PropTypes: timeTypes, ValueType: TypeObject, Arity: ArityOne},
+	"time_between": {Description: "the time of day of a is from b.from up to b.to", PropTypes: timeTypes, ValueType: TypeObject, Arity: ArityOne},
+	"date_between": {Description: "the date of a is from b.from through b.to", PropTypes: timeTypes, ValueType: TypeObject, Arity: ArityOne},
+
+	"exists":    {Description: "the path exists, even if its value is null", Arity: ArityNone, Negation: "notexists"},
+	"notexists": {Description: "the path does not exist", Arity: ArityNone, Negation: "exists"},
+	"isnull":    {Description: "the path exists and its value is null", Arity: ArityNone},
+	"isempty":   {Description: "the path does not exist, is null, or is an empty string, slice or map", Arity: ArityNone, Negation: "notempty"},
+	"notempty":  {Description: "the path holds a value that is not null or empty", Arity: ArityNone, Negation: "isempty"},
+}
+
+// defaultComparatorInfos will return a new map with the descriptions of all
+// the default comparators
+func defaultComparatorInfos() map[string]ComparatorInfo {
+	infos := make(map[string]ComparatorInfo, len(defaultInfos))
+	for name, info := range defaultInfos {
+		info.Name = name
+		infos[name] = info
+	}
+	return infos
+}
+
+// Comparators will return the descriptions of all of the comparators the
+// engine can use, sorted by name. Comparators added without a description
+// only have a name.
+func (e Engine) Comparators() []ComparatorInfo {
+	var infos []ComparatorInfo
+	for name := range e.comparators {
+		infos = append(infos, e.info(name))
+	}
+	for name := range presenceComparators {
+		infos = append(infos, e.info(name))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// info will return the description of the comparator, or just its name if
+// it has none
+func (e Engine) info(name string) ComparatorInfo {
+	if info, ok := e.infos[name]; ok {
+		return info
+	}
+	return ComparatorInfo{Name: name}
+}
+
+// Validate will check that every rule in the engine uses a comparator that
+// the engine knows, and that the rule's value fits the comparator's
+// description. Engines can be validated after adding custom comparators.
+func (e Engine) Validate() error {
+	var errs []error
+	for i, c := range e.Composites {
+		for j, r := range c.Rules {
+			errs = append(errs, e.validate(fmt.Sprintf("composites[%d].rules[%d]", i, j), r)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate will check a single rule and the rules nested in its quantifier,
+// where tells the caller where the rule is in the engine
+func (e Engine) validate(where string, r rule) []error {
+	var errs []error
+	if q := r.Quantifier; q != nil {
+		if q.Rule != nil {
+			errs = append(errs, e.validate(where+".rule", *q.Rule)...)
+		}
+		if q.Composite != nil {
+			for i, nested := range q.Composite.Rules {
+				errs = append(errs, e.validate(fmt.Sprintf("%s.composite.rules[%d]", where, i), nested)...)
+			}
+		}
+		if q.Operator != QuantifierCount {
+			return errs
+		}
+	}
+
+	_, known := e.comparators[r.Comparator]
+	_, presence := presenceComparators[r.Comparator]
+	if !known && !presence {
+		return append(errs, fmt.Errorf("%s: unknown comparator %q", where, r.Comparator))
+	}
+
+	err := e.info(r.Comparator).validateValue(r.Value)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", where, err))
+	}
+	return errs
+}
+
+// validateValue will check that the value of a rule fits the description of
+// its comparator. References are resolved at evaluation time, and values
+// that were parsed when the rule was loaded have already been checked.
+func (info ComparatorInfo) validateValue(v interface{}) error {
+	var list []interface{}
+	switch t := v.(type) {
+	case *pathValue, paramValue, *exprValue:
+		return nil
+	case map[interface{}]struct{}:
+		for k := range t {
+			list = append(list, k)
+		}
+	default:
+		if _, parsed := valueParsers[info.Name]; parsed {
+			return nil
+		}
+	}
+
+	switch info.Arity {
+	case ArityNone:
+		if v != nil {
+			return fmt.Errorf("%s does not take a value", info.Name)
+		}
+	case ArityOne:
+		if v == nil || list != nil {
+			return fmt.Errorf("%s takes a single value", info.Name)
+		}
+		if info.ValueType != "" && typeOf(v) != info.ValueType {
+			return fmt.Errorf("%s value must be a %s", info.Name, info.ValueType)
+		}
+	case ArityList:
+		if list == nil {
+			return fmt.Errorf("%s takes a list of values", info.Name)
+		}
+		for _, item := range list {
+			if info.ValueType != "" && typeOf(item) != info.ValueType {
+				return fmt.Errorf("%s values must be of type %s", info.Name, info.ValueType)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package grules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDefaultInfos(t *testing.T) {
+	comps := defaultComparers()
+	for name := range comps {
+		if _, ok := defaultInfos[name]; !ok {
+			t.Fatalf("expected comparator %s to have a description", name)
+		}
+	}
+	for name := range presenceComparators {
+		if _, ok := defaultInfos[name]; !ok {
+			t.Fatalf("expected comparator %s to have a description", name)
+		}
+	}
+
+	for name, info := range defaultInfos {
+		_, known := comps[name]
+		_, presence := presenceComparators[name]
+		if !known && !presence {
+			t.Fatalf("expected description %s to have a comparator", name)
+		}
+		if info.Negation == "" {
+			continue
+		}
+		if _, ok := defaultInfos[info.Negation]; !ok {
+			t.Fatalf("expected negation %s of %s to exist", info.Negation, name)
+		}
+	}
+}
+
+func TestEngine_Comparators(t *testing.T) {
+	e, err := NewJSONEngine(json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = e.AddComparator("always-false", func(a, b interface{}) bool {
+		return false
+	})
+	e = e.RegisterComparator(ComparatorInfo{
+		Name:        "divisible_by",
+		Description: "a is divisible by b",
+		PropTypes:   []string{TypeNumber},
+		ValueType:   TypeNumber,
+		Arity:       ArityOne,
+	}, Comparator(func(a, b interface{}) bool {
+		return false
+	}))
+
+	infos := e.Comparators()
+	if len(infos) != len(defaultInfos)+2 {
+		t.Fatalf("expected %d comparators, got %d", len(defaultInfos)+2, len(infos))
+	}
+	for i := 1; i < len(infos); i++ {
+		if infos[i-1].Name >= infos[i].Name {
+			t.Fatal("expected comparators to be sorted by name")
+		}
+	}
+
+	byName := make(map[string]ComparatorInfo)
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+	if byName["eq"].Negation != "neq" || byName["eq"].Description == "" {
+		t.Fatalf("expected eq to be described, got %+v", byName["eq"])
+	}
+	if byName["exists"].Arity != ArityNone {
+		t.Fatalf("expected exists to be described, got %+v", byName["exists"])
+	}
+	if byName["always-false"].Name != "always-false" || byName["always-false"].Description != "" {
+		t.Fatalf("expected always-false to only have a name, got %+v", byName["always-false"])
+	}
+	if byName["divisible_by"].ValueType != TypeNumber {
+		t.Fatalf("expected divisible_by to be described, got %+v", byName["divisible_by"])
+	}
+}
+
+func TestEngine_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"a","value":"b"},{"comparator":"oneof","path":"a","value":["b","c"]},{"comparator":"exists","path":"a"},{"comparator":"within_radius","path":"loc","value":{"lat":1,"lon":2,"radius":3}},{"comparator":"lt","path":"a","value":{"$param":"max"}},{"count":"items","rule":{"comparator":"is_string","path":"name"},"comparator":"gte","value":1},{"comparator":"custom","path":"a","value":[1]}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e = e.AddComparator("custom", equal)
+
+		err = e.Validate()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"unknown","path":"a","value":"b"},{"comparator":"oneof","path":"a","value":"b"},{"comparator":"eq","path":"a","value":["b"]},{"comparator":"is_string","path":"a","value":"b"},{"comparator":"regex","path":"a","value":1},{"any":"items","rule":{"comparator":"approx_eq","path":"a"}}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = e.Validate()
+		if err == nil {
+			t.Fatal("expected engine to be invalid")
+		}
+
+		expected := []string{
+			`composites[0].rules[0]: unknown comparator "unknown"`,
+			`composites[0].rules[1]: oneof takes a list of values`,
+			`composites[0].rules[2]: eq takes a single value`,
+			`composites[0].rules[3]: is_string does not take a value`,
+			`composites[0].rules[4]: regex value must be a string`,
+			`composites[0].rules[5].rule: approx_eq takes a single value`,
+		}
+		if err.Error() != strings.Join(expected, "\n") {
+			t.Fatalf("expected errors to be:\n%s\ngot:\n%s", strings.Join(expected, "\n"), err)
+		}
+	})
+}
@@ -0,0 +1,306 @@
+package grules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// fieldTree is the part of a JSON document that an engine's rules refer to,
+// a node with all set needs its whole value, otherwise only its children
+type fieldTree struct {
+	all      bool
+	children map[string]*fieldTree
+}
+
+// add will add the leading keys of the path to the tree, the value at the
+// last of them is needed whole, including everything a wildcard or filter
+// after them could reach
+func (t *fieldTree) add(p *path) {
+	for _, seg := range p.segments {
+		if t.all {
+			return
+		}
+		if seg.kind != segmentKey {
+			break
+		}
+		if t.children == nil {
+			t.children = make(map[string]*fieldTree)
+		}
+		child, ok := t.children[seg.key]
+		if !ok {
+			child = &fieldTree{}
+			t.children[seg.key] = child
+		}
+		t = child
+	}
+	t.all = true
+	t.children = nil
+}
+
+// addPath will compile the path and add it to the tree
+func (t *fieldTree) addPath(src string) {
+	if src == PathNow {
+		return
+	}
+	p, err := compilePath(src)
+	if err != nil {
+		return
+	}
+	t.add(p)
+}
+
+// jsonFields will return the tree of every path the engine's rules refer to.
+// The rules nested in a quantifier refer to elements of the slice at the
+// quantifier's path, which is needed whole.
+func (e Engine) jsonFields() *fieldTree {
+	t := &fieldTree{}
+	for _, c := range e.Composites {
+		for _, r := range c.Rules {
+			t.addRule(r)
+		}
+	}
+	return t
+}
+
+// addRule will add every path the rule refers to
+func (t *fieldTree) addRule(r rule) {
+	switch {
+	case r.Quantifier != nil:
+		t.addPath(r.Quantifier.Path)
+	case r.Expr != nil:
+		r.Expr.paths(t.add)
+	default:
+		if target, err := r.compiledTarget(); err == nil && target.path != nil {
+			t.add(target.path)
+		}
+	}
+
+	switch v := r.Value.(type) {
+	case *pathValue:
+		t.add(v.path)
+	case *exprValue:
+		v.expr.paths(t.add)
+	}
+}
+
+// errJSONObject is returned when a document that is evaluated is not an
+// object
+var errJSONObject = errors.New("json: document must be an object")
+
+// extractJSON will decode only the parts of the JSON document in data that
+// are in the tree, skipping over everything else. Only the parts that are
+// decoded are checked to be valid JSON.
+func extractJSON(data []byte, t *fieldTree) (map[string]interface{}, error) {
+	s := jsonScanner{data: data}
+	s.skipSpace()
+	if s.done() || s.data[s.pos] != '{' {
+		return nil, errJSONObject
+	}
+
+	v, err := s.extract(t)
+	if err != nil {
+		return nil, err
+	}
+
+	s.skipSpace()
+	if !s.done() {
+		return nil, s.errorf("unexpected %q after the document", s.data[s.pos])
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// jsonScanner walks a JSON document one byte at a time
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+// errorf will return a syntax error at the current position
+func (s *jsonScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("json: %s at offset %d", fmt.Sprintf(format, args...), s.pos)
+}
+
+// done will return true if there is nothing left to scan
+func (s *jsonScanner) done() bool {
+	return s.pos >= len(s.data)
+}
+
+// skipSpace will move past any whitespace
+func (s *jsonScanner) skipSpace() {
+	for !s.done() {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect will move past c, or return an error if it is not next
+func (s *jsonScanner) expect(c byte) error {
+	s.skipSpace()
+	if s.done() || s.data[s.pos] != c {
+		return s.errorf("expected %q", c)
+	}
+	s.pos++
+	return nil
+}
+
+// extract will decode the parts of the value at the current position that
+// are in the tree, objects with children in the tree are walked key by key
+func (s *jsonScanner) extract(t *fieldTree) (interface{}, error) {
+	s.skipSpace()
+	if t.all || s.done() || s.data[s.pos] != '{' {
+		return s.decode()
+	}
+
+	m := make(map[string]interface{}, len(t.children))
+	s.pos++
+	s.skipSpace()
+	if !s.done() && s.data[s.pos] == '}' {
+		s.pos++
+		return m, nil
+	}
+
+	for {
+		key, err := s.key()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(':'); err != nil {
+			return nil, err
+		}
+
+		if child, ok := t.children[key]; ok {
+			m[key], err = s.extract(child)
+		} else {
+			err = s.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.skipSpace()
+		if s.done() {
+			return nil, s.errorf("unexpected end of object")
+		}
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return m, nil
+		default:
+			return nil, s.errorf("unexpected %q in object", s.data[s.pos])
+		}
+	}
+}
+
+// decode will decode the whole value at the current position
+func (s *jsonScanner) decode() (interface{}, error) {
+	start := s.pos
+	if err := s.skip(); err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(s.data[start:s.pos], &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// key will read the key of an object
+func (s *jsonScanner) key() (string, error) {
+	s.skipSpace()
+	start := s.pos
+	escaped, err := s.skipString()
+	if err != nil {
+		return "", err
+	}
+
+	raw := s.data[start+1 : s.pos-1]
+	if !escaped {
+		return string(raw), nil
+	}
+
+	var key string
+	if err := json.Unmarshal(s.data[start:s.pos], &key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// skipString will move past the string at the current position, reporting
+// whether it has any escapes
+func (s *jsonScanner) skipString() (bool, error) {
+	if s.done() || s.data[s.pos] != '"' {
+		return false, s.errorf("expected string")
+	}
+	s.pos++
+
+	var escaped bool
+	for !s.done() {
+		switch s.data[s.pos] {
+		case '\\':
+			escaped = true
+			s.pos += 2
+		case '"':
+			s.pos++
+			return escaped, nil
+		default:
+			s.pos++
+		}
+	}
+	return false, s.errorf("unterminated string")
+}
+
+// skip will move past the value at the current position without decoding
+// it
+func (s *jsonScanner) skip() error {
+	s.skipSpace()
+	if s.done() {
+		return s.errorf("unexpected end of document")
+	}
+
+	switch s.data[s.pos] {
+	case '"':
+		_, err := s.skipString()
+		return err
+	case '{', '[':
+		depth := 0
+		for !s.done() {
+			switch s.data[s.pos] {
+			case '"':
+				if _, err := s.skipString(); err != nil {
+					return err
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			s.pos++
+			if depth == 0 {
+				return nil
+			}
+		}
+		return s.errorf("unexpected end of document")
+	}
+
+	start := s.pos
+	for !s.done() {
+		switch s.data[s.pos] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			if s.pos == start {
+				return s.errorf("unexpected %q", s.data[s.pos])
+			}
+			return nil
+		}
+		s.pos++
+	}
+	return nil
+}
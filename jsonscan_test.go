@@ -0,0 +1,173 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+	"user": {"name": "Trevor", "email": null, "tags": ["a", "b"], "address": {"city": "Atlanta"}},
+	"headers": {"x.forwarded.for": "10.0.0.1", "user-agent": "curl"},
+	"order": {
+		"total": 120.5,
+		"discount": 20,
+		"items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 3}]
+	},
+	"limits": {"daily": 100},
+	"esc\"aped": "yes",
+	"padding": {"deep": [[1, 2, {"x": "]}"}], "\"]}"], "n": -1.5e3, "t": true, "f": false}
+}`
+
+func TestEngine_EvaluateJSON(t *testing.T) {
+	rules := []string{
+		`{"comparator":"eq","path":"user.name","value":"Trevor"}`,
+		`{"comparator":"eq","path":"user.name","value":"Bob"}`,
+		`{"comparator":"isnull","path":"user.email"}`,
+		`{"comparator":"exists","path":"user.phone"}`,
+		`{"comparator":"notexists","path":"user.phone"}`,
+		`{"comparator":"eq","path":"user.tags.-1","value":"b"}`,
+		`{"comparator":"contains","path":"user.tags","value":"a"}`,
+		`{"comparator":"eq","path":"user.address.city","value":"Atlanta"}`,
+		`{"comparator":"eq","path":"headers[\"x.forwarded.for\"]","value":"10.0.0.1"}`,
+		`{"comparator":"eq","path":"esc\\\\\"aped","value":"yes"}`,
+		`{"comparator":"eq","path":"order.items.*.sku","value":"b"}`,
+		`{"comparator":"gt","path":"$.order.items[?(@.sku == 'b')].qty","value":2}`,
+		`{"any":"order.items","rule":{"comparator":"gt","path":"qty","value":2}}`,
+		`{"comparator":"gt","expr":"order.total - order.discount","value":100}`,
+		`{"comparator":"lt","path":"order.total","value":{"$path":"limits.daily"}}`,
+		`{"comparator":"gt","path":"order.total","value":{"$expr":"limits.daily + 10"}}`,
+		`{"comparator":"exists","path":"$"}`,
+	}
+
+	var props map[string]interface{}
+	if err := json.Unmarshal([]byte(testDocument), &props); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range rules {
+		j := `{"composites":[{"operator":"and","rules":[` + r + `]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := e.EvaluateJSON([]byte(testDocument))
+		if err != nil {
+			t.Fatalf("expected %s to evaluate, got %v", r, err)
+		}
+		if expected := e.Evaluate(props); res != expected {
+			t.Fatalf("expected %s to be %v, got %v", r, expected, res)
+		}
+	}
+
+	t.Run("composites changed", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"user.name","value":"Trevor"}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"order.items.1.sku","value":"b"}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Composites = append(e.Composites, other.Composites...)
+
+		res, err := e.EvaluateJSON([]byte(testDocument))
+		if err != nil || !res {
+			t.Fatalf("expected the added composite's paths to be decoded, got %v, %v", res, err)
+		}
+	})
+}
+
+func TestExtractJSON(t *testing.T) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"user.name","value":"Trevor"},{"comparator":"gt","path":"order.items.*.qty","value":2}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props, err := extractJSON([]byte(testDocument), e.jsonFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 2 {
+		t.Fatalf("expected only user and order to be decoded, got %v", props)
+	}
+	user := props["user"].(map[string]interface{})
+	if len(user) != 1 || user["name"] != "Trevor" {
+		t.Fatalf("expected only user.name to be decoded, got %v", user)
+	}
+	if _, ok := props["order"].(map[string]interface{})["items"].([]interface{}); !ok {
+		t.Fatalf("expected order.items to be decoded whole, got %v", props["order"])
+	}
+
+	cases := []struct {
+		doc string
+		err bool
+	}{
+		{doc: `{}`},
+		{doc: ` {"user": {"name": "Trevor"}} `},
+		{doc: `{"other": [1, 2, tru, nul], "user": {"name": "Trevor"}}`},
+		{doc: `[]`, err: true},
+		{doc: ``, err: true},
+		{doc: `{"user": {"name": }}`, err: true},
+		{doc: `{"user": {"name": "Trevor"}`, err: true},
+		{doc: `{"user": {"name": "Trevor"}} x`, err: true},
+		{doc: `{"user" {"name": "Trevor"}}`, err: true},
+		{doc: `{"user": {"name": "Trev`, err: true},
+	}
+
+	for _, c := range cases {
+		_, err := e.EvaluateJSON([]byte(c.doc))
+		if (err != nil) != c.err {
+			t.Fatalf("expected %q to have error %v, got %v", c.doc, c.err, err)
+		}
+	}
+}
+
+// largeDocument will return a JSON document with n orders, which rules do
+// not refer to, and a small user object that they do
+func largeDocument(n int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"orders":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"id":"o-%d","total":%d.5,"status":"shipped","items":[{"sku":"a","qty":1},{"sku":"b","qty":2}],"note":"a \"quoted\" note"}`, i, i)
+	}
+	b.WriteString(`],"user":{"name":"Trevor","country":"US","age":30}}`)
+	return []byte(b.String())
+}
+
+const benchmarkJSONEngine = `{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"user.country","value":"US"},{"comparator":"gte","path":"user.age","value":18}]}]}`
+
+func BenchmarkEngine_EvaluateJSON(b *testing.B) {
+	e, err := NewJSONEngine(json.RawMessage(benchmarkJSONEngine))
+	if err != nil {
+		b.Fatal(err)
+	}
+	raw := largeDocument(1000)
+
+	b.SetBytes(int64(len(raw)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.EvaluateJSON(raw)
+	}
+}
+
+func BenchmarkEngine_EvaluateUnmarshaledJSON(b *testing.B) {
+	e, err := NewJSONEngine(json.RawMessage(benchmarkJSONEngine))
+	if err != nil {
+		b.Fatal(err)
+	}
+	raw := largeDocument(1000)
+
+	b.SetBytes(int64(len(raw)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var props map[string]interface{}
+		json.Unmarshal(raw, &props)
+		e.Evaluate(props)
+	}
+}
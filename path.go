@@ -0,0 +1,582 @@
+package grules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MatchAny is true if any of the values a path yields satisfy the
+	// comparator, it is the default
+	MatchAny = "any"
+	// MatchAll is true if all of the values a path yields satisfy the
+	// comparator
+	MatchAll = "all"
+)
+
+const (
+	segmentKey = iota
+	segmentWildcard
+	segmentFilter
+)
+
+// path is a compiled path into the props. Paths are keys delimited by '.',
+// where numeric keys index into slices, and can also use the JSONPath style
+// forms $ for the root, [0] for an index, ["a.b"] for a key with special
+// characters, * or [*] for every element, and [?(@.qty > 1)] for the
+// elements that match a filter. A backslash escapes the byte after it in a
+// key. Paths with a wildcard or a filter can yield many values.
+type path struct {
+	src string
+	// key is the path in the form String prints it, it is what a
+	// PropertySource is given
+	key      string
+	segments []segment
+	multi    bool
+}
+
+// segment is a single step in a path
+type segment struct {
+	kind   int
+	key    string
+	filter *filter
+}
+
+// filter is a [?(...)] segment, it keeps the elements whose value at the
+// relative path compares true against the value. A filter without an
+// operator keeps the elements where the relative path has a value.
+type filter struct {
+	path  *path
+	op    string
+	value interface{}
+}
+
+// filterOps are the operators a filter can use
+var filterOps = map[string]Comparator{
+	"==": equal,
+	"!=": notEqual,
+	">=": greaterThanEqual,
+	"<=": lessThanEqual,
+	">":  greaterThan,
+	"<":  lessThan,
+}
+
+// compilePath will parse the path in src
+func compilePath(src string) (*path, error) {
+	p := &path{src: src}
+	s := &pathScanner{src: src}
+
+	switch {
+	case src == "$":
+		p.key = src
+		return p, nil
+	case strings.HasPrefix(src, "$.") || strings.HasPrefix(src, "$["):
+		s.pos = 1
+	case strings.HasPrefix(src, "["):
+	default:
+		// The first key does not start with a '.'
+		seg, err := s.key("")
+		if err != nil {
+			return nil, err
+		}
+		p.segments = append(p.segments, seg)
+	}
+
+	segs, err := s.segments("")
+	if err != nil {
+		return nil, err
+	}
+	p.segments = append(p.segments, segs...)
+
+	for _, seg := range p.segments {
+		if seg.kind != segmentKey {
+			p.multi = true
+		}
+	}
+	p.key = p.String()
+
+	return p, nil
+}
+
+// splitPath will return the path for src, a plain path of keys delimited by
+// '.', see plainPath, without scanning it. Its key is src as it is.
+func splitPath(src string) *path {
+	keys := strings.Split(src, ".")
+	p := &path{src: src, key: src, segments: make([]segment, len(keys))}
+	for i, key := range keys {
+		p.segments[i] = segment{kind: segmentKey, key: key}
+	}
+	return p
+}
+
+// pathScanner reads the segments of a path one byte at a time
+type pathScanner struct {
+	src string
+	pos int
+}
+
+// errorf will return an error about the path being scanned
+func (s *pathScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("path %q: %s", s.src, fmt.Sprintf(format, args...))
+}
+
+// done will return true if there is nothing left to scan
+func (s *pathScanner) done() bool {
+	return s.pos >= len(s.src)
+}
+
+// skipSpace will move past any spaces
+func (s *pathScanner) skipSpace() {
+	for !s.done() && s.src[s.pos] == ' ' {
+		s.pos++
+	}
+}
+
+// expect will move past c, or return an error if it is not next
+func (s *pathScanner) expect(c byte) error {
+	if s.done() || s.src[s.pos] != c {
+		return s.errorf("expected %q at %d", c, s.pos)
+	}
+	s.pos++
+	return nil
+}
+
+// segments will scan segments until the end of the path, or until a byte in
+// stop
+func (s *pathScanner) segments(stop string) ([]segment, error) {
+	var segs []segment
+	for !s.done() && strings.IndexByte(stop, s.src[s.pos]) < 0 {
+		var seg segment
+		var err error
+		switch s.src[s.pos] {
+		case '.':
+			if strings.HasPrefix(s.src[s.pos:], "..") {
+				return nil, s.errorf("recursive descent .. at %d is not supported", s.pos)
+			}
+			s.pos++
+			seg, err = s.key(stop)
+		case '[':
+			seg, err = s.bracket()
+		default:
+			err = s.errorf("unexpected %q at %d", s.src[s.pos], s.pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+// key will scan a key up to the next '.', '[' or byte in stop. A backslash
+// escapes the byte after it, and a key of * is a wildcard unless escaped.
+func (s *pathScanner) key(stop string) (segment, error) {
+	var b strings.Builder
+	var escaped bool
+	for !s.done() {
+		c := s.src[s.pos]
+		if c == '\\' {
+			if s.pos+1 >= len(s.src) {
+				return segment{}, s.errorf("trailing \\")
+			}
+			b.WriteByte(s.src[s.pos+1])
+			s.pos += 2
+			escaped = true
+			continue
+		}
+		if c == '.' || c == '[' || strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		b.WriteByte(c)
+		s.pos++
+	}
+
+	if b.String() == "*" && !escaped {
+		return segment{kind: segmentWildcard}, nil
+	}
+	return segment{kind: segmentKey, key: b.String()}, nil
+}
+
+// bracket will scan a quoted key, an index, a wildcard or a filter in
+// brackets
+func (s *pathScanner) bracket() (segment, error) {
+	s.pos++
+	s.skipSpace()
+	if s.done() {
+		return segment{}, s.errorf("unterminated [")
+	}
+
+	var seg segment
+	switch c := s.src[s.pos]; {
+	case c == '\'' || c == '"':
+		key, err := s.quoted()
+		if err != nil {
+			return segment{}, err
+		}
+		seg = segment{kind: segmentKey, key: key}
+	case c == '*':
+		s.pos++
+		seg = segment{kind: segmentWildcard}
+	case strings.HasPrefix(s.src[s.pos:], "?("):
+		s.pos += 2
+		f, err := s.filter()
+		if err != nil {
+			return segment{}, err
+		}
+		seg = segment{kind: segmentFilter, filter: f}
+	default:
+		end := strings.IndexByte(s.src[s.pos:], ']')
+		if end < 0 {
+			return segment{}, s.errorf("unterminated [")
+		}
+		inner := strings.TrimSpace(s.src[s.pos : s.pos+end])
+		if _, err := strconv.Atoi(inner); err != nil {
+			return segment{}, s.errorf("%q is not an index", inner)
+		}
+		s.pos += end
+		seg = segment{kind: segmentKey, key: inner}
+	}
+
+	s.skipSpace()
+	if err := s.expect(']'); err != nil {
+		return segment{}, err
+	}
+	return seg, nil
+}
+
+// quoted will scan a string in single or double quotes, a backslash escapes
+// the byte after it
+func (s *pathScanner) quoted() (string, error) {
+	q := s.src[s.pos]
+	s.pos++
+
+	var b strings.Builder
+	for !s.done() {
+		c := s.src[s.pos]
+		switch {
+		case c == q:
+			s.pos++
+			return b.String(), nil
+		case c == '\\' && s.pos+1 < len(s.src):
+			b.WriteByte(s.src[s.pos+1])
+			s.pos += 2
+		default:
+			b.WriteByte(c)
+			s.pos++
+		}
+	}
+
+	return "", s.errorf("unterminated string")
+}
+
+// filter will scan the inside of a [?(...)] filter, e.g. @.qty > 1, up to
+// and including the closing ')'
+func (s *pathScanner) filter() (*filter, error) {
+	s.skipSpace()
+	if err := s.expect('@'); err != nil {
+		return nil, s.errorf("filter must start with @")
+	}
+
+	// The relative path runs up to the operator, if there is one
+	start := s.pos
+	segs, err := s.segments(" =!<>)")
+	if err != nil {
+		return nil, err
+	}
+	f := filter{path: &path{src: "$" + s.src[start:s.pos], segments: segs}}
+	for _, seg := range segs {
+		if seg.kind != segmentKey {
+			return nil, s.errorf("filter paths must not yield many values")
+		}
+	}
+
+	s.skipSpace()
+	if !s.done() && s.src[s.pos] == ')' {
+		s.pos++
+		return &f, nil
+	}
+
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if strings.HasPrefix(s.src[s.pos:], op) {
+			f.op = op
+			s.pos += len(op)
+			break
+		}
+	}
+	if f.op == "" {
+		return nil, s.errorf("unknown filter operator at %d", s.pos)
+	}
+
+	s.skipSpace()
+	if s.done() {
+		return nil, s.errorf("unterminated filter")
+	}
+	if c := s.src[s.pos]; c == '\'' || c == '"' {
+		f.value, err = s.quoted()
+	} else {
+		end := strings.IndexAny(s.src[s.pos:], " )")
+		if end < 0 {
+			return nil, s.errorf("unterminated filter")
+		}
+		f.value, err = parseLiteral(s.src[s.pos : s.pos+end])
+		s.pos += end
+	}
+	if err != nil {
+		return nil, s.errorf("%v", err)
+	}
+
+	s.skipSpace()
+	if err := s.expect(')'); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// parseLiteral will parse a number or a boolean
+func parseLiteral(s string) (interface{}, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number, string or boolean", s)
+	}
+	return f, nil
+}
+
+// JoinPath will return a path to the value under each of the keys in turn,
+// quoting the keys that contain '.' or any other byte that means something
+// in a path, e.g. JoinPath("headers", "x.forwarded.for") is
+// headers["x.forwarded.for"]
+func JoinPath(keys ...string) string {
+	segs := make([]segment, len(keys))
+	for i, key := range keys {
+		segs[i] = segment{kind: segmentKey, key: key}
+	}
+	return formatSegments(segs, true)
+}
+
+// String will return the path in a form that compiles back to the same path,
+// keys are quoted only where they need to be
+func (p *path) String() string {
+	return formatSegments(p.segments, true)
+}
+
+// formatSegments will print the segments of a path, root is true if they
+// start at the root of the props rather than at a filter's element
+func formatSegments(segs []segment, root bool) string {
+	if root && len(segs) == 0 {
+		return "$"
+	}
+
+	var b strings.Builder
+	for i, seg := range segs {
+		switch seg.kind {
+		case segmentKey:
+			if !plainKey(seg.key) {
+				b.WriteByte('[')
+				b.WriteString(quote(seg.key, '"'))
+				b.WriteByte(']')
+				continue
+			}
+			if i > 0 || !root {
+				b.WriteByte('.')
+			}
+			b.WriteString(seg.key)
+		case segmentWildcard:
+			b.WriteString("[*]")
+		case segmentFilter:
+			b.WriteString("[?(@")
+			b.WriteString(formatSegments(seg.filter.path.segments, false))
+			if seg.filter.op != "" {
+				b.WriteByte(' ')
+				b.WriteString(seg.filter.op)
+				b.WriteByte(' ')
+				b.WriteString(formatLiteral(seg.filter.value))
+			}
+			b.WriteString(")]")
+		}
+	}
+	return b.String()
+}
+
+// plainKey will return true if the key can be written in a path without
+// quotes
+func plainKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	return !strings.ContainsAny(key, ".[]\\'\"*$@()=!<> ")
+}
+
+// quote will put s in quotes, escaping the quote and backslashes
+func quote(s string, q byte) string {
+	var b strings.Builder
+	b.WriteByte(q)
+	for i := 0; i < len(s); i++ {
+		if s[i] == q || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte(q)
+	return b.String()
+}
+
+// formatLiteral will print a filter's value so that it parses back the same
+func formatLiteral(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return quote(t, '\'')
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// lookupFrom will return the value at the path starting from root, and
+// whether the path exists. A path that yields many values returns the first
+// of them, in the order values yields them.
+func (p *path) lookupFrom(root interface{}) (interface{}, bool) {
+	if p.multi {
+		vals := p.values(root)
+		if len(vals) == 0 {
+			return nil, false
+		}
+		return vals[0], true
+	}
+
+	val := root
+	for _, seg := range p.segments {
+		var ok bool
+		val, ok = step(val, seg.key)
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// values will return every value the path yields starting from root
+func (p *path) values(root interface{}) []interface{} {
+	return p.valuesFrom(root, p.segments)
+}
+
+// valuesFrom will return every value that segs, the tail of the path, yield
+// starting from v
+func (p *path) valuesFrom(v interface{}, segs []segment) []interface{} {
+	vals := []interface{}{v}
+	for _, seg := range segs {
+		var next []interface{}
+		for _, v := range vals {
+			switch seg.kind {
+			case segmentKey:
+				if val, ok := step(v, seg.key); ok {
+					next = append(next, val)
+				}
+			case segmentWildcard:
+				next = appendChildren(next, v, nil)
+			case segmentFilter:
+				next = appendChildren(next, v, seg.filter)
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+// step will move from v to its child at key, which is either a key in a map
+// or an index into a slice
+func step(v interface{}, key string) (interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		val, ok := m[key]
+		return val, ok
+	}
+	return index(v, key)
+}
+
+// appendChildren will append every element of the slice, map or struct v
+// that matches the filter, or all of them if there is no filter. The values
+// of a map are appended in the order of their keys, so that the values of a
+// path are always in the same order.
+func appendChildren(dst []interface{}, v interface{}, f *filter) []interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if child := m[key]; f == nil || f.match(child) {
+				dst = append(dst, child)
+			}
+		}
+		return dst
+	}
+
+	if t, ok := v.([]map[string]interface{}); ok {
+		for _, child := range t {
+			if f == nil || f.match(child) {
+				dst = append(dst, child)
+			}
+		}
+		return dst
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			child := plainValue(rv.MapIndex(key))
+			if f == nil || f.match(child) {
+				dst = append(dst, child)
+			}
+		}
+		return dst
+	case reflect.Struct:
+		plan := planOf(rv.Type())
+		for _, name := range plan.names {
+			child, ok := plan.field(rv, name)
+			if ok && (f == nil || f.match(child)) {
+				dst = append(dst, child)
+			}
+		}
+		return dst
+	}
+
+	eachElement(v, func(child interface{}) bool {
+		if f == nil || f.match(child) {
+			dst = append(dst, child)
+		}
+		return true
+	})
+	return dst
+}
+
+// match will return true if the element passes the filter
+func (f *filter) match(elem interface{}) bool {
+	val, ok := f.path.lookupFrom(elem)
+	if !ok || val == nil {
+		return false
+	}
+	if f.op == "" {
+		return true
+	}
+	return filterOps[f.op](val, f.value)
+}
@@ -0,0 +1,267 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompilePath(t *testing.T) {
+	cases := []struct {
+		path    string
+		printed string
+		multi   bool
+		err     bool
+	}{
+		{path: "user.name", printed: "user.name"},
+		{path: "user.addresses.0.zip", printed: "user.addresses.0.zip"},
+		{path: "$.user.name", printed: "user.name"},
+		{path: "$", printed: "$"},
+		{path: "$now", printed: `["$now"]`},
+		{path: "", printed: `[""]`},
+		{path: "user.addresses[0].zip", printed: "user.addresses.0.zip"},
+		{path: "user.addresses[-1]", printed: "user.addresses.-1"},
+		{path: `headers["x.forwarded.for"]`, printed: `headers["x.forwarded.for"]`},
+		{path: `headers['x.forwarded.for']`, printed: `headers["x.forwarded.for"]`},
+		{path: `headers.x\.forwarded\.for`, printed: `headers["x.forwarded.for"]`},
+		{path: `["example.com"].hits`, printed: `["example.com"].hits`},
+		{path: `metrics["say \"hi\""]`, printed: `metrics["say \"hi\""]`},
+		{path: `metrics['a\\b']`, printed: `metrics["a\\b"]`},
+		{path: `files.\*`, printed: `files["*"]`},
+		{path: `files["*"]`, printed: `files["*"]`},
+		{path: "orders.*.status", printed: "orders[*].status", multi: true},
+		{path: "$.orders[*].status", printed: "orders[*].status", multi: true},
+		{path: "$.items[?(@.qty > 1)].sku", printed: "items[?(@.qty > 1)].sku", multi: true},
+		{path: "$.items[?(@.sku == 'b')]", printed: "items[?(@.sku == 'b')]", multi: true},
+		{path: `$.items[?(@["a.b"] == "x)]")]`, printed: `items[?(@["a.b"] == 'x)]')]`, multi: true},
+		{path: "$.items[?(@.gift)]", printed: "items[?(@.gift)]", multi: true},
+		{path: "$.items[?(@ >= 10)]", printed: "items[?(@ >= 10)]", multi: true},
+		{path: `headers["x.forwarded.for`, err: true},
+		{path: `headers.x\`, err: true},
+		{path: "user.addresses[0", err: true},
+		{path: "$..a", err: true},
+		{path: "user..name", err: true},
+		{path: "$.items[?(@..qty > 1)]", err: true},
+		{path: "user.addresses[first]", err: true},
+		{path: "$.items[?(@.qty > 1]", err: true},
+		{path: "$.items[?(qty > 1)]", err: true},
+		{path: "$.items[?(@.qty ~ 1)]", err: true},
+		{path: "$.items[?(@.qty > one)]", err: true},
+		{path: "$.items[?(@.tags[*] == 'a')]", err: true},
+		{path: "user]name", printed: `["user]name"]`},
+	}
+
+	for _, c := range cases {
+		p, err := compilePath(c.path)
+		if c.err {
+			if err == nil {
+				t.Fatalf("expected %q to be an error", c.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected %q to compile, got %v", c.path, err)
+		}
+		if p.multi != c.multi {
+			t.Fatalf("expected %q to have multi %v", c.path, c.multi)
+		}
+		if p.String() != c.printed {
+			t.Fatalf("expected %q to print as %q, got %q", c.path, c.printed, p.String())
+		}
+
+		// The printed path must compile back to the same path
+		again, err := compilePath(p.String())
+		if err != nil {
+			t.Fatalf("expected %q to compile, got %v", p.String(), err)
+		}
+		if again.String() != p.String() {
+			t.Fatalf("expected %q to round trip, got %q", p.String(), again.String())
+		}
+	}
+}
+
+func TestPath_quotedKeys(t *testing.T) {
+	props := map[string]interface{}{
+		"headers": map[string]interface{}{
+			"x.forwarded.for": "10.0.0.1",
+			"x": map[string]interface{}{
+				"forwarded": map[string]interface{}{"for": "wrong"},
+			},
+		},
+		"example.com": map[string]interface{}{"hits": float64(3)},
+		"*":           "star",
+	}
+
+	cases := []struct {
+		path     string
+		expected interface{}
+	}{
+		{path: `headers["x.forwarded.for"]`, expected: "10.0.0.1"},
+		{path: `headers['x.forwarded.for']`, expected: "10.0.0.1"},
+		{path: `headers.x\.forwarded\.for`, expected: "10.0.0.1"},
+		{path: "headers.x.forwarded.for", expected: "wrong"},
+		{path: `["example.com"].hits`, expected: float64(3)},
+		{path: `$["example.com"]["hits"]`, expected: float64(3)},
+		{path: `\*`, expected: "star"},
+		{path: JoinPath("headers", "x.forwarded.for"), expected: "10.0.0.1"},
+		{path: JoinPath("example.com", "hits"), expected: float64(3)},
+	}
+
+	for _, c := range cases {
+		val, found := lookup(props, c.path)
+		if !found || val != c.expected {
+			t.Fatalf("expected %s to be %v, got %v", c.path, c.expected, val)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	cases := []struct {
+		keys     []string
+		expected string
+	}{
+		{keys: []string{"user", "name"}, expected: "user.name"},
+		{keys: []string{"headers", "x.forwarded.for"}, expected: `headers["x.forwarded.for"]`},
+		{keys: []string{"a b", `c"d`}, expected: `["a b"]["c\"d"]`},
+		{keys: nil, expected: "$"},
+	}
+
+	for _, c := range cases {
+		got := JoinPath(c.keys...)
+		if got != c.expected {
+			t.Fatalf("expected %v to join as %s, got %s", c.keys, c.expected, got)
+		}
+	}
+}
+
+func TestPath_values(t *testing.T) {
+	props := map[string]interface{}{
+		"orders": map[string]interface{}{
+			"o-1": map[string]interface{}{"status": "shipped"},
+			"o-2": map[string]interface{}{"status": "pending"},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": float64(1)},
+			map[string]interface{}{"sku": "b", "qty": float64(3), "gift": true},
+			map[string]interface{}{"sku": "c", "qty": float64(5)},
+		},
+		"scores": []float64{5, 10, 20},
+		"codes":  map[string]string{"b": "y", "a": "x", "c": "z"},
+	}
+
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "orders.*.status", expected: []string{"shipped", "pending"}},
+		{path: "$.orders[*].status", expected: []string{"shipped", "pending"}},
+		{path: "codes.*", expected: []string{"x", "y", "z"}},
+		{path: "items.*.sku", expected: []string{"a", "b", "c"}},
+		{path: "$.items[*].sku", expected: []string{"a", "b", "c"}},
+		{path: "$.items[?(@.qty > 1)].sku", expected: []string{"b", "c"}},
+		{path: "$.items[?(@.qty <= 3)].sku", expected: []string{"a", "b"}},
+		{path: "$.items[?(@.sku != 'a')].sku", expected: []string{"b", "c"}},
+		{path: `$.items[?(@.sku == "c")].sku`, expected: []string{"c"}},
+		{path: "$.items[?(@.gift)].sku", expected: []string{"b"}},
+		{path: "$.items[?(@.gift == true)].sku", expected: []string{"b"}},
+		{path: "$.items[?(@.qty > 10)].sku", expected: nil},
+		{path: "$.items[-1].sku", expected: []string{"c"}},
+		{path: "missing.*.sku", expected: nil},
+		{path: "items.*.missing", expected: nil},
+	}
+
+	for _, c := range cases {
+		p, err := compilePath(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, v := range p.values(props) {
+			got = append(got, v.(string))
+		}
+
+		if len(got) != len(c.expected) {
+			t.Fatalf("expected %s to yield %v, got %v", c.path, c.expected, got)
+		}
+		for i := range got {
+			if got[i] != c.expected[i] {
+				t.Fatalf("expected %s to yield %v, got %v", c.path, c.expected, got)
+			}
+		}
+	}
+
+	p, err := compilePath("$.scores[?(@ >= 10)]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals := p.values(props); len(vals) != 2 {
+		t.Fatalf("expected 2 scores, got %v", vals)
+	}
+}
+
+func TestRule_evaluateMatch(t *testing.T) {
+	props := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": float64(1)},
+			map[string]interface{}{"sku": "b", "qty": float64(3)},
+		},
+	}
+
+	cases := []struct {
+		json     string
+		expected bool
+	}{
+		{json: `{"comparator":"eq","path":"items.*.sku","value":"b"}`, expected: true},
+		{json: `{"comparator":"eq","path":"items.*.sku","value":"b","match":"any"}`, expected: true},
+		{json: `{"comparator":"eq","path":"items.*.sku","value":"b","match":"all"}`, expected: false},
+		{json: `{"comparator":"gte","path":"$.items[*].qty","value":1,"match":"all"}`, expected: true},
+		{json: `{"comparator":"gt","path":"$.items[?(@.sku == 'b')].qty","value":2,"match":"all"}`, expected: true},
+		{json: `{"comparator":"eq","path":"items.*.missing","value":"b"}`, expected: false},
+		{json: `{"comparator":"eq","path":"items.*.missing","value":"b","match":"all"}`, expected: false},
+		{json: `{"comparator":"exists","path":"items.*.sku","match":"all"}`, expected: true},
+		{json: `{"comparator":"notexists","path":"items.*.missing"}`, expected: true},
+		{json: `{"comparator":"eq","path":"items[1].sku","value":"b"}`, expected: true},
+	}
+
+	for _, c := range cases {
+		var r rule
+		err := json.Unmarshal([]byte(c.json), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+		if res != c.expected {
+			t.Fatalf("expected %s to be %v, got %v", c.json, c.expected, res)
+		}
+	}
+
+	for _, j := range []string{
+		`{"comparator":"eq","path":"items.*.sku","value":"b","match":"some"}`,
+		`{"comparator":"eq","path":"items[","value":"b"}`,
+		`{"any":"items[?(@.qty)","rule":{"comparator":"eq","value":"b"}}`,
+	} {
+		var r rule
+		if err := json.Unmarshal([]byte(j), &r); err == nil {
+			t.Fatalf("expected %s to be an error", j)
+		}
+	}
+}
+
+func BenchmarkPathWildcard(b *testing.B) {
+	props := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": float64(1)},
+			map[string]interface{}{"sku": "b", "qty": float64(3)},
+			map[string]interface{}{"sku": "c", "qty": float64(5)},
+		},
+	}
+	p, err := compilePath("$.items[?(@.qty > 1)].sku")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.values(props)
+	}
+}
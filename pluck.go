@@ -1,18 +1,176 @@
 package grules
 
 import (
+	"reflect"
+	"strconv"
 	"strings"
 )
 
 // pluck will pull out the value from the props given a path delimited by '.'
 func pluck(props map[string]interface{}, path string) interface{} {
-	parts := strings.Split(path, ".")
-	for i := 0; i < len(parts)-1; i++ {
+	val, _ := lookup(props, path)
+	return val
+}
+
+// lookup will pull out the value from the props given a path delimited by '.',
+// the second return value reports whether the path exists at all, which lets
+// callers tell a missing key apart from a key that is explicitly null. Parts
+// of the path that are numbers index into slices, negative numbers count back
+// from the end, e.g. "user.addresses.-1.zip". Paths in the JSONPath style,
+// e.g. "$.orders[*].status", and paths with quoted or escaped keys, e.g.
+// `headers["x.forwarded.for"]`, are compiled first and give their first value.
+func lookup(props map[string]interface{}, path string) (interface{}, bool) {
+	return lookupValue(props, path)
+}
+
+// lookupValue will pull out the value from v given a path, like lookup, v can
+// be any value that paths can walk, e.g. a struct
+func lookupValue(v interface{}, path string) (interface{}, bool) {
+	if !plainPath(path) {
+		p, err := compilePath(path)
+		if err != nil {
+			return nil, false
+		}
+		return p.lookupFrom(v)
+	}
+
+	val := v
+	for _, part := range strings.Split(path, ".") {
 		var ok bool
-		props, ok = props[parts[i]].(map[string]interface{})
+		val, ok = step(val, part)
 		if !ok {
-			return nil
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// plainPath will return true if the path is only keys delimited by '.', so it
+// can be split rather than compiled
+func plainPath(path string) bool {
+	return !strings.ContainsAny(path, `$*[\(`)
+}
+
+// index will return the element of the slice v at the index in part, or the
+// member of a struct or map under part, it returns false if there is no such
+// element
+func index(v interface{}, part string) (interface{}, bool) {
+	switch t := v.(type) {
+	case []interface{}:
+		i, ok := parseIndex(part, len(t))
+		if !ok {
+			return nil, false
+		}
+		return t[i], true
+	case []string:
+		i, ok := parseIndex(part, len(t))
+		if !ok {
+			return nil, false
+		}
+		return t[i], true
+	case []float64:
+		i, ok := parseIndex(part, len(t))
+		if !ok {
+			return nil, false
+		}
+		return t[i], true
+	case []map[string]interface{}:
+		i, ok := parseIndex(part, len(t))
+		if !ok {
+			return nil, false
+		}
+		return t[i], true
+	case nil:
+		return nil, false
+	}
+
+	return member(reflect.ValueOf(v), part)
+}
+
+// member will return the element of a slice or array, the value in a map
+// with string or integer keys, or the field of a struct in rv under key,
+// following pointers on the way. The value is made plain, see plainValue.
+func member(rv reflect.Value, key string) (interface{}, bool) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
 		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, ok := parseIndex(key, rv.Len())
+		if !ok {
+			return nil, false
+		}
+		return plainValue(rv.Index(i)), true
+	case reflect.Map:
+		k, ok := mapKey(rv.Type().Key(), key)
+		if !ok {
+			return nil, false
+		}
+		val := rv.MapIndex(k)
+		if !val.IsValid() {
+			return nil, false
+		}
+		return plainValue(val), true
+	case reflect.Struct:
+		return planOf(rv.Type()).field(rv, key)
+	}
+
+	return nil, false
+}
+
+// mapKey will convert a key in a path to a key of the map key type kt, maps
+// can have string or integer keys
+func mapKey(kt reflect.Type, key string) (reflect.Value, bool) {
+	switch kt.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(kt), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowInt(i) {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(i).Convert(kt), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || reflect.Zero(kt).OverflowUint(u) {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(u).Convert(kt), true
+	}
+	return reflect.Value{}, false
+}
+
+// parseIndex will parse the index in part for a slice of length n, negative
+// indexes count back from the end
+func parseIndex(part string, n int) (int, bool) {
+	digits := part
+	if strings.HasPrefix(part, "-") {
+		digits = part[1:]
+	}
+	if digits == "" {
+		return 0, false
+	}
+
+	var i int
+	for _, c := range []byte(digits) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		i = i*10 + int(c-'0')
+		if i > n {
+			return 0, false
+		}
+	}
+
+	if len(digits) != len(part) {
+		i = n - i
+	}
+	if i < 0 || i >= n {
+		return 0, false
 	}
-	return props[parts[len(parts)-1]]
+	return i, true
 }
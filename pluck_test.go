@@ -79,3 +79,177 @@ func BenchmarkPluckDeep(b *testing.B) {
 		pluck(props, "this.is.a.super.deep.map.hello")
 	}
 }
+
+func TestLookup(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "Trevor",
+			"email": nil,
+		},
+	}
+
+	t.Run("key exists", func(t *testing.T) {
+		val, found := lookup(props, "user.name")
+		if !found || val.(string) != "Trevor" {
+			t.Fatal("expected value to be found")
+		}
+	})
+
+	t.Run("key exists, null value", func(t *testing.T) {
+		val, found := lookup(props, "user.email")
+		if !found || val != nil {
+			t.Fatal("expected nil value to be found")
+		}
+	})
+
+	t.Run("key does not exist", func(t *testing.T) {
+		_, found := lookup(props, "user.phone")
+		if found {
+			t.Fatal("expected value to not be found")
+		}
+	})
+
+	t.Run("parent does not exist", func(t *testing.T) {
+		_, found := lookup(props, "account.id")
+		if found {
+			t.Fatal("expected value to not be found")
+		}
+	})
+}
+
+func TestLookupIndex(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"zip": "30303"},
+				map[string]interface{}{"zip": "10001"},
+			},
+			"tags":   []string{"a", "b", "c"},
+			"scores": []float64{1, 2},
+			"orders": []map[string]interface{}{
+				{"id": "o-1"},
+			},
+			"matrix": []interface{}{
+				[]interface{}{float64(1), float64(2)},
+			},
+			"codes": []int{7, 8},
+			"fixed": [2]string{"x", "y"},
+		},
+	}
+
+	cases := []struct {
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{path: "user.addresses.0.zip", expected: "30303", found: true},
+		{path: "user.addresses.1.zip", expected: "10001", found: true},
+		{path: "user.addresses.-1.zip", expected: "10001", found: true},
+		{path: "user.addresses.-2.zip", expected: "30303", found: true},
+		{path: "user.addresses.2.zip", found: false},
+		{path: "user.addresses.-3.zip", found: false},
+		{path: "user.addresses.first.zip", found: false},
+		{path: "user.addresses.-", found: false},
+		{path: "user.tags.2", expected: "c", found: true},
+		{path: "user.scores.-1", expected: float64(2), found: true},
+		{path: "user.orders.0.id", expected: "o-1", found: true},
+		{path: "user.matrix.0.1", expected: float64(2), found: true},
+		{path: "user.codes.1", expected: float64(8), found: true},
+		{path: "user.fixed.0", expected: "x", found: true},
+		{path: "user.tags.0.name", found: false},
+	}
+
+	for _, c := range cases {
+		val, found := lookup(props, c.path)
+		if found != c.found || val != c.expected {
+			t.Fatalf("expected %s to be %v, %v, got %v, %v", c.path, c.expected, c.found, val, found)
+		}
+	}
+}
+
+func TestLookupTyped(t *testing.T) {
+	props := map[string]interface{}{
+		"labels":   map[string]string{"tier": "gold", "x.y": "z"},
+		"counts":   map[string]map[string]int{"eu": {"fr": 3}},
+		"ids":      map[int]string{7: "seven"},
+		"limits":   map[uint8]float32{1: 1.5},
+		"statuses": map[testStatus]bool{"active": true},
+		"orders": []map[string]interface{}{
+			{"id": "o-1", "lines": []map[string]int{{"qty": 2}}},
+		},
+		"matrix": [][]int{{1, 2}, {3, 4}},
+		"nested": map[string]interface{}{
+			"deep": map[string][]string{"tags": {"a", "b"}},
+		},
+		"points": map[bool]string{true: "yes"},
+	}
+
+	cases := []struct {
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{path: "labels.tier", expected: "gold", found: true},
+		{path: `labels["x.y"]`, expected: "z", found: true},
+		{path: "labels.missing", found: false},
+		{path: "counts.eu.fr", expected: float64(3), found: true},
+		{path: "counts.us.fr", found: false},
+		{path: "ids.7", expected: "seven", found: true},
+		{path: "ids.seven", found: false},
+		{path: "limits.1", expected: float64(1.5), found: true},
+		{path: "limits.256", found: false},
+		{path: "limits.-1", found: false},
+		{path: "statuses.active", expected: true, found: true},
+		{path: "orders.0.lines.0.qty", expected: float64(2), found: true},
+		{path: "matrix.-1.0", expected: float64(3), found: true},
+		{path: "nested.deep.tags.1", expected: "b", found: true},
+		{path: "points.true", found: false},
+	}
+
+	for _, c := range cases {
+		val, found := lookup(props, c.path)
+		if found != c.found || val != c.expected {
+			t.Fatalf("expected %s to be %v, %v, got %v, %v", c.path, c.expected, c.found, val, found)
+		}
+	}
+
+	p, err := compilePath("counts.*.fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals := p.values(props); len(vals) != 1 || vals[0] != float64(3) {
+		t.Fatalf("expected a wildcard over a typed map to yield 3, got %v", vals)
+	}
+	p, err = compilePath("$.labels[?(@ == 'gold')]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals := p.values(props); len(vals) != 1 || vals[0] != "gold" {
+		t.Fatalf("expected a filter over a typed map to yield gold, got %v", vals)
+	}
+}
+
+func BenchmarkPluckTyped(b *testing.B) {
+	props := map[string]interface{}{
+		"counts": map[string]map[string]int{"eu": {"fr": 3}},
+	}
+
+	for i := 0; i < b.N; i++ {
+		pluck(props, "counts.eu.fr")
+	}
+}
+
+func BenchmarkPluckIndex(b *testing.B) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"zip": "30303"},
+				map[string]interface{}{"zip": "10001"},
+			},
+		},
+	}
+
+	for i := 0; i < b.N; i++ {
+		pluck(props, "user.addresses.-1.zip")
+	}
+}
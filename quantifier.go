@@ -0,0 +1,208 @@
+package grules
+
+import (
+	"fmt"
+)
+
+const (
+	// QuantifierAny is true if at least one element matches
+	QuantifierAny = "any"
+	// QuantifierAll is true if every element matches
+	QuantifierAll = "all"
+	// QuantifierNone is true if no element matches
+	QuantifierNone = "none"
+	// QuantifierCount compares the number of matching elements with the
+	// rule's comparator and value
+	QuantifierCount = "count"
+)
+
+// quantifier evaluates a nested rule or composite against every element of
+// the slice at the path. Paths inside the nested rule or composite are
+// relative to the element, an element that is not a map can be reached with
+// an empty path. A path that yields many values, e.g. "orders.*.items.0",
+// quantifies over the values it yields.
+type quantifier struct {
+	Operator  string
+	Path      string
+	Rule      *rule
+	Composite *composite
+	path      *path
+}
+
+// quantifiedRule is the JSON representation of a rule with a quantifier, e.g.
+// {"any":"order.items","rule":{...}} or
+// {"count":"order.items","rule":{...},"comparator":"gte","value":2}
+type quantifiedRule struct {
+	Any        string      `json:"any,omitempty"`
+	All        string      `json:"all,omitempty"`
+	None       string      `json:"none,omitempty"`
+	Count      string      `json:"count,omitempty"`
+	Rule       *rule       `json:"rule,omitempty"`
+	Composite  *composite  `json:"composite,omitempty"`
+	Comparator string      `json:"comparator,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+	Options    Options     `json:"options,omitempty"`
+}
+
+// newQuantifier will return the quantifier described by qr, or nil if qr does
+// not name one. It will return an error if qr names more than one, has no
+// rule or composite to match the elements with, or is a count without a
+// comparator.
+func newQuantifier(qr quantifiedRule) (*quantifier, error) {
+	q := quantifier{
+		Rule:      qr.Rule,
+		Composite: qr.Composite,
+	}
+
+	for _, o := range []struct {
+		op, path string
+	}{
+		{QuantifierAny, qr.Any},
+		{QuantifierAll, qr.All},
+		{QuantifierNone, qr.None},
+		{QuantifierCount, qr.Count},
+	} {
+		if o.path == "" {
+			continue
+		}
+		if q.Operator != "" {
+			return nil, fmt.Errorf("quantifier has both %q and %q", q.Operator, o.op)
+		}
+		q.Operator, q.Path = o.op, o.path
+	}
+
+	switch {
+	case q.Operator == "":
+		return nil, nil
+	case q.Rule == nil && q.Composite == nil:
+		return nil, fmt.Errorf("quantifier %q on %q needs a rule or a composite", q.Operator, q.Path)
+	case q.Rule != nil && q.Composite != nil:
+		return nil, fmt.Errorf("quantifier %q on %q has both a rule and a composite", q.Operator, q.Path)
+	case q.Operator == QuantifierCount && qr.Comparator == "":
+		return nil, fmt.Errorf("quantifier %q on %q needs a comparator", q.Operator, q.Path)
+	}
+
+	var err error
+	q.path, err = compilePath(q.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+// quantifiedRule will put the quantifier back into its JSON representation
+func (q *quantifier) quantifiedRule() quantifiedRule {
+	qr := quantifiedRule{
+		Rule:      q.Rule,
+		Composite: q.Composite,
+	}
+
+	switch q.Operator {
+	case QuantifierAny:
+		qr.Any = q.Path
+	case QuantifierAll:
+		qr.All = q.Path
+	case QuantifierNone:
+		qr.None = q.Path
+	case QuantifierCount:
+		qr.Count = q.Path
+	}
+
+	return qr
+}
+
+// eachSource will call fn with the source of every element of the slice v
+// until fn returns false. It reports whether v was a slice it knows how to
+// walk.
+func eachSource(v interface{}, fn func(src PropertySource) bool) bool {
+	if t, ok := v.([]map[string]interface{}); ok {
+		for _, e := range t {
+			if !fn(MapSource(e)) {
+				break
+			}
+		}
+		return true
+	}
+
+	return eachElement(v, func(e interface{}) bool {
+		return fn(elementSource(e))
+	})
+}
+
+// evaluate will apply the quantifier to the slice in the props. r is the rule
+// that holds the quantifier, its comparator and value are used by count.
+func (q *quantifier) evaluate(r rule, ev *evaluation) bool {
+	var matches int
+	var done, res bool
+	ok := eachSource(q.elements(ev), func(e PropertySource) bool {
+		matched := q.match(ev.with(e))
+		switch {
+		case matched && q.Operator == QuantifierAny:
+			done, res = true, true
+		case matched && q.Operator == QuantifierNone:
+			done, res = true, false
+		case !matched && q.Operator == QuantifierAll:
+			done, res = true, false
+		case matched:
+			matches++
+		}
+		return !done
+	})
+	if !ok {
+		return false
+	}
+	if done {
+		return res
+	}
+
+	switch q.Operator {
+	case QuantifierAny:
+		return false
+	case QuantifierAll, QuantifierNone:
+		return true
+	case QuantifierCount:
+		comp, ok := ev.comps[r.Comparator]
+		if !ok {
+			return false
+		}
+		value, ok := r.value(ev)
+		if !ok {
+			return false
+		}
+		return comp.Compare(float64(matches), value, r.Options)
+	}
+
+	return false
+}
+
+// elements will return the slice the quantifier walks, which is every value
+// the path yields if it can yield many
+func (q *quantifier) elements(ev *evaluation) interface{} {
+	p := q.path
+	if p == nil {
+		var err error
+		p, err = compilePath(q.Path)
+		if err != nil {
+			return nil
+		}
+	}
+
+	if p.multi {
+		return ev.values(p)
+	}
+	val, _ := ev.lookup(p)
+	return val
+}
+
+// match will evaluate the nested rule or composite against a single element
+func (q *quantifier) match(ev *evaluation) bool {
+	switch {
+	case q.Rule != nil:
+		return q.Rule.evaluate(ev)
+	case q.Composite != nil:
+		return q.Composite.evaluate(ev)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQuantifier_evaluate(t *testing.T) {
+	props := map[string]interface{}{
+		"order": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"sku": "a", "qty": float64(1)},
+				map[string]interface{}{"sku": "b", "qty": float64(3)},
+				map[string]interface{}{"sku": "c", "qty": float64(5)},
+			},
+			"scores": []float64{10, 95},
+			"lines":  []map[string]string{{"sku": "a"}, {"sku": "a"}},
+			"counts": []int{1, 2, 3},
+			"empty":  []interface{}{},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		json     string
+		expected bool
+	}{
+		{name: "any", json: `{"any":"order.items","rule":{"comparator":"eq","path":"sku","value":"b"}}`, expected: true},
+		{name: "any, no match", json: `{"any":"order.items","rule":{"comparator":"eq","path":"sku","value":"z"}}`, expected: false},
+		{name: "all", json: `{"all":"order.items","rule":{"comparator":"gte","path":"qty","value":1}}`, expected: true},
+		{name: "all, one fails", json: `{"all":"order.items","rule":{"comparator":"gt","path":"qty","value":1}}`, expected: false},
+		{name: "none", json: `{"none":"order.items","rule":{"comparator":"gt","path":"qty","value":10}}`, expected: true},
+		{name: "none, one matches", json: `{"none":"order.items","rule":{"comparator":"gt","path":"qty","value":4}}`, expected: false},
+		{name: "count", json: `{"count":"order.items","rule":{"comparator":"gt","path":"qty","value":1},"comparator":"gte","value":2}`, expected: true},
+		{name: "count, too few", json: `{"count":"order.items","rule":{"comparator":"gt","path":"qty","value":1},"comparator":"gte","value":3}`, expected: false},
+		{name: "composite", json: `{"any":"order.items","composite":{"operator":"and","rules":[{"comparator":"eq","path":"sku","value":"c"},{"comparator":"gt","path":"qty","value":4}]}}`, expected: true},
+		{name: "scalar elements", json: `{"any":"order.scores","rule":{"comparator":"gt","value":90}}`, expected: true},
+		{name: "all, empty", json: `{"all":"order.empty","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: true},
+		{name: "any, empty", json: `{"any":"order.empty","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: false},
+		{name: "not a slice", json: `{"all":"order","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: false},
+		{name: "missing", json: `{"none":"order.missing","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: false},
+		{name: "typed", json: `{"all":"order.lines","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: true},
+		{name: "typed scalars", json: `{"count":"order.counts","rule":{"comparator":"gt","value":1},"comparator":"eq","value":2}`, expected: true},
+		{name: "wildcard", json: `{"count":"order.items[*].qty","rule":{"comparator":"gt","value":2},"comparator":"eq","value":2}`, expected: true},
+		{name: "filter", json: `{"all":"$.order.items[?(@.qty > 1)]","rule":{"comparator":"neq","path":"sku","value":"a"}}`, expected: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r rule
+			err := json.Unmarshal([]byte(c.json), &r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Quantifier == nil {
+				t.Fatal("expected rule to have a quantifier")
+			}
+			res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+			if res != c.expected {
+				t.Fatalf("expected rule to be %v, got %v", c.expected, res)
+			}
+		})
+	}
+}
+
+func TestQuantifier_invalid(t *testing.T) {
+	cases := []string{
+		`{"any":"items"}`,
+		`{"any":"items","all":"items","rule":{"comparator":"eq","path":"sku","value":"b"}}`,
+		`{"none":"items","count":"items","rule":{"comparator":"eq","path":"sku","value":"b"},"comparator":"gte","value":1}`,
+		`{"any":"items","rule":{"comparator":"eq","path":"sku","value":"b"},"composite":{"operator":"and","rules":[]}}`,
+		`{"count":"items","rule":{"comparator":"eq","path":"sku","value":"b"},"value":2}`,
+		`{"any":"items[","rule":{"comparator":"eq","path":"sku","value":"b"}}`,
+	}
+
+	for _, j := range cases {
+		var r rule
+		err := json.Unmarshal([]byte(j), &r)
+		if err == nil {
+			t.Fatalf("expected an error for %s", j)
+		}
+	}
+}
+
+func TestQuantifier_MarshalJSON(t *testing.T) {
+	cases := []string{
+		`{"composites":[{"operator":"and","rules":[{"any":"order.items","rule":{"comparator":"eq","path":"sku","value":"b"}}]}]}`,
+		`{"composites":[{"operator":"and","rules":[{"count":"order.items","rule":{"comparator":"gt","path":"qty","value":1},"comparator":"gte","value":2}]}]}`,
+		`{"composites":[{"operator":"and","rules":[{"all":"order.items","composite":{"operator":"or","rules":[{"comparator":"eq","path":"sku","value":"a"}]}}]}]}`,
+	}
+
+	for _, j := range cases {
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	}
+}
+
+func BenchmarkQuantifier_evaluate(b *testing.B) {
+	var r rule
+	err := json.Unmarshal([]byte(`{"any":"items","rule":{"comparator":"unit","path":"sku","value":"c"}}`), &r)
+	if err != nil {
+		b.Fatal(err)
+	}
+	props := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a"},
+			map[string]interface{}{"sku": "b"},
+			map[string]interface{}{"sku": "c"},
+		},
+	}
+	comps := map[string]Comparer{
+		"unit": Comparator(func(a, b interface{}) bool {
+			return a == b
+		}),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.evaluate(newEvaluation(MapSource(props), comps))
+	}
+}
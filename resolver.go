@@ -0,0 +1,197 @@
+package grules
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Resolver computes the value of a path prefix when a rule first needs it,
+// e.g. an account balance that has to be fetched. It is given the context of
+// the evaluation and the props being evaluated.
+type Resolver func(ctx context.Context, props PropertySource) (interface{}, error)
+
+// resolverEntry is a resolver and the prefix it was added for
+type resolverEntry struct {
+	prefix string
+	fn     Resolver
+}
+
+// resolverTree finds the resolver for the longest prefix of a path
+type resolverTree struct {
+	entry    *resolverEntry
+	children map[string]*resolverTree
+}
+
+// AddResolver will add a resolver for the path prefix, e.g. "account.balance"
+// or "fraud". A rule whose path starts with the prefix gets its value from
+// the resolver, the rest of the path is walked in what the resolver returns.
+// Resolvers are called at most once per evaluation, and only if a rule
+// needs them. The resolver for the longest prefix of a path wins. It will
+// return a copy of the engine with the resolver, the engine it is called on
+// is not changed, or an error if the prefix is not a path of keys.
+func (e Engine) AddResolver(prefix string, r Resolver) (Engine, error) {
+	p, err := compilePath(prefix)
+	if err != nil {
+		return e, err
+	}
+	if len(p.segments) == 0 || p.multi {
+		return e, fmt.Errorf("resolver prefix %q must be a path of keys", prefix)
+	}
+
+	e.resolvers = e.resolvers.with(p.segments, &resolverEntry{prefix: p.key, fn: r})
+	return e, nil
+}
+
+// with will return a copy of the tree with entry at the path of segs. Only
+// the nodes along the path are copied, the rest are shared with t, which is
+// not changed. t can be nil.
+func (t *resolverTree) with(segs []segment, entry *resolverEntry) *resolverTree {
+	var copied resolverTree
+	if t != nil {
+		copied = *t
+	}
+	if len(segs) == 0 {
+		copied.entry = entry
+		return &copied
+	}
+
+	children := make(map[string]*resolverTree, len(copied.children)+1)
+	for key, child := range copied.children {
+		children[key] = child
+	}
+	key := segs[0].key
+	children[key] = children[key].with(segs[1:], entry)
+	copied.children = children
+	return &copied
+}
+
+// match will return the resolver for the longest prefix of segs and the
+// number of segments the prefix covers
+func (t *resolverTree) match(segs []segment) (*resolverEntry, int) {
+	var entry *resolverEntry
+	var n int
+	for i, seg := range segs {
+		if seg.kind != segmentKey {
+			break
+		}
+		t = t.children[seg.key]
+		if t == nil {
+			break
+		}
+		if t.entry != nil {
+			entry, n = t.entry, i+1
+		}
+	}
+	return entry, n
+}
+
+// resolved is the memoized result of calling a resolver
+type resolved struct {
+	val interface{}
+	err error
+}
+
+// resolvingSource is a PropertySource that gets the values under resolver
+// prefixes from their resolvers, and everything else from the props
+type resolvingSource struct {
+	PropertySource
+	// props are what the resolvers are given, they are the source unless
+	// it only holds part of the props
+	props     PropertySource
+	ctx       context.Context
+	resolvers *resolverTree
+	memo      map[*resolverEntry]resolved
+
+	// err is the first error of the evaluation, once there is one no more
+	// resolvers are called
+	err error
+}
+
+// newResolvingSource will return a source for a single evaluation of props
+func newResolvingSource(ctx context.Context, props PropertySource, resolvers *resolverTree) *resolvingSource {
+	return &resolvingSource{
+		PropertySource: props,
+		props:          props,
+		ctx:            ctx,
+		resolvers:      resolvers,
+		memo:           make(map[*resolverEntry]resolved),
+	}
+}
+
+// Get will return the value at the path, resolving it if it is under a
+// resolver prefix. Plain paths are split rather than compiled.
+func (s *resolvingSource) Get(path string) (interface{}, bool) {
+	if plainPath(path) {
+		return s.lookupPath(splitPath(path))
+	}
+
+	p, err := compilePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return s.lookupPath(p)
+}
+
+func (s *resolvingSource) lookupPath(p *path) (interface{}, bool) {
+	entry, n := s.resolvers.match(p.segments)
+	if entry == nil {
+		return sourceLookup(s.PropertySource, p)
+	}
+
+	val, ok := s.resolve(entry)
+	if !ok {
+		return nil, false
+	}
+	if p.multi {
+		vals := p.valuesFrom(val, p.segments[n:])
+		if len(vals) == 0 {
+			return nil, false
+		}
+		return vals[0], true
+	}
+	for _, seg := range p.segments[n:] {
+		val, ok = step(val, seg.key)
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+func (s *resolvingSource) pathValues(p *path) []interface{} {
+	entry, n := s.resolvers.match(p.segments)
+	if entry == nil {
+		return sourceValues(s.PropertySource, p)
+	}
+
+	val, ok := s.resolve(entry)
+	if !ok {
+		return nil
+	}
+	return p.valuesFrom(val, p.segments[n:])
+}
+
+// resolve will call the resolver the first time its value is needed, and
+// return the same value after that
+func (s *resolvingSource) resolve(entry *resolverEntry) (interface{}, bool) {
+	if r, ok := s.memo[entry]; ok {
+		return r.val, r.err == nil
+	}
+	if s.err != nil {
+		return nil, false
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+		return nil, false
+	}
+
+	val, err := entry.fn(s.ctx, s.props)
+	if err != nil {
+		err = fmt.Errorf("resolving %s: %w", entry.prefix, err)
+		s.err = err
+	}
+	r := resolved{val: plainValue(reflect.ValueOf(val)), err: err}
+	s.memo[entry] = r
+	return r.val, err == nil
+}
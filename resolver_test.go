@@ -0,0 +1,184 @@
+package grules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEngine_EvaluateContext(t *testing.T) {
+	j := `{"composites":[{"operator":"and","rules":[
+		{"comparator":"eq","path":"user.country","value":"US"},
+		{"comparator":"gt","path":"account.balance","value":100},
+		{"comparator":"lt","path":"account.balance","value":1000},
+		{"comparator":"lt","path":"fraud.score","value":0.5},
+		{"comparator":"eq","path":"fraud.reasons.0","value":"none"}
+	]}]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(map[string]int)
+	e, err = e.AddResolver("account.balance", func(ctx context.Context, props PropertySource) (interface{}, error) {
+		calls["balance"]++
+		id, _ := props.Get("user.id")
+		if id != "u-1" {
+			return nil, errors.New("unknown user")
+		}
+		return 250, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = e.AddResolver("fraud", func(ctx context.Context, props PropertySource) (interface{}, error) {
+		calls["fraud"]++
+		return map[string]interface{}{"score": 0.1, "reasons": []interface{}{"none"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := map[string]interface{}{
+		"user": map[string]interface{}{"id": "u-1", "country": "US"},
+	}
+	res, err := e.EvaluateContext(context.Background(), props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res {
+		t.Fatal("expected engine to be true")
+	}
+	if calls["balance"] != 1 || calls["fraud"] != 1 {
+		t.Fatalf("expected each resolver to be called once, got %v", calls)
+	}
+
+	t.Run("not needed", func(t *testing.T) {
+		calls = make(map[string]int)
+		props := map[string]interface{}{
+			"user": map[string]interface{}{"id": "u-1", "country": "CA"},
+		}
+		res, err := e.EvaluateContext(context.Background(), props)
+		if err != nil || res {
+			t.Fatalf("expected engine to be false, got %v, %v", res, err)
+		}
+		if len(calls) != 0 {
+			t.Fatalf("expected no resolvers to be called, got %v", calls)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		calls = make(map[string]int)
+		props := map[string]interface{}{
+			"user": map[string]interface{}{"id": "u-2", "country": "US"},
+		}
+		res, err := e.EvaluateContext(context.Background(), props)
+		if err == nil || res {
+			t.Fatalf("expected an error, got %v, %v", res, err)
+		}
+		if calls["balance"] != 1 || calls["fraud"] != 0 {
+			t.Fatalf("expected no resolvers to be called after the error, got %v", calls)
+		}
+		if e.Evaluate(props) {
+			t.Fatal("expected Evaluate to be false when a resolver fails")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		tests := []struct {
+			doc      string
+			expected bool
+			err      bool
+		}{
+			{`{"user": {"id": "u-1", "country": "US"}}`, true, false},
+			{`{"user": {"country": "US", "id": "u-2"}}`, false, true},
+			{`{"user": {"country": "CA", "id": "u-2"}}`, false, false},
+		}
+
+		for _, tc := range tests {
+			calls = make(map[string]int)
+			res, err := e.EvaluateJSON([]byte(tc.doc))
+			if (err != nil) != tc.err || res != tc.expected {
+				t.Fatalf("expected %s to be %v with error %v, got %v, %v", tc.doc, tc.expected, tc.err, res, err)
+			}
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		calls = make(map[string]int)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := e.EvaluateContext(ctx, props)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context to be canceled, got %v", err)
+		}
+		if len(calls) != 0 {
+			t.Fatalf("expected no resolvers to be called, got %v", calls)
+		}
+	})
+}
+
+func TestEngine_AddResolver(t *testing.T) {
+	j := `{"composites":[{"operator":"and","rules":[
+		{"comparator":"eq","path":"account.tier","value":"gold"},
+		{"comparator":"eq","path":"account.limits.daily","value":500},
+		{"comparator":"eq","path":"account.history.*.status","value":"paid","match":"all"},
+		{"any":"account.history","rule":{"comparator":"gt","path":"amount","value":10}}
+	]}]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err = e.AddResolver("account", func(ctx context.Context, props PropertySource) (interface{}, error) {
+		return map[string]interface{}{
+			"tier":   "silver",
+			"limits": map[string]interface{}{"daily": float64(100)},
+			"history": []interface{}{
+				map[string]interface{}{"status": "paid", "amount": float64(20)},
+				map[string]interface{}{"status": "paid", "amount": float64(5)},
+			},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = e.AddResolver("account.tier", func(ctx context.Context, props PropertySource) (interface{}, error) {
+		return "gold", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = e.AddResolver("account.limits.daily", func(ctx context.Context, props PropertySource) (interface{}, error) {
+		return int64(500), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Evaluate(map[string]interface{}{}) {
+		t.Fatal("expected the longest prefix to win")
+	}
+
+	t.Run("copies the engine", func(t *testing.T) {
+		tier, err := e.AddResolver("account.tier", func(ctx context.Context, props PropertySource) (interface{}, error) {
+			return "bronze", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tier.Evaluate(map[string]interface{}{}) {
+			t.Fatal("expected the copy to use its own resolver")
+		}
+		if !e.Evaluate(map[string]interface{}{}) {
+			t.Fatal("expected the original engine to keep its resolvers")
+		}
+	})
+
+	for _, prefix := range []string{"$", "orders.*", "orders[", "$.items[?(@.qty > 1)]"} {
+		if _, err := e.AddResolver(prefix, nil); err == nil {
+			t.Fatalf("expected prefix %q to be an error", prefix)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package grules
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
@@ -9,6 +15,10 @@ const (
 	OperatorAnd = "and"
 	// OperatorOr is what identifies the OR condition in a composite
 	OperatorOr = "or"
+
+	// DefaultEpsilon is the absolute tolerance used by approx_eq and
+	// approx_neq when neither the rule nor the engine gives one
+	DefaultEpsilon = 1e-9
 )
 
 // defaultComparators is a map of all the default comparators that
@@ -24,18 +34,138 @@ var defaultComparators = map[string]Comparator{
 	"ncontains": notContains,
 	"oneof":     oneOf,
 	"noneof":    noneOf,
-	"regex":     regex,
+
+	"containsall":  containsAll,
+	"containsany":  containsAny,
+	"containsnone": containsNone,
+	"subsetof":     subsetOf,
+	"supersetof":   supersetOf,
+	"intersects":   intersects,
+
+	"within_radius":  withinRadius,
+	"within_polygon": withinPolygon,
+
+	"levenshtein_lte": levenshteinLessThanEqual,
+	"similarity_gte":  similarityGreaterThanEqual,
+	"soundex_eq":      soundexEqual,
+	"metaphone_eq":    metaphoneEqual,
+
+	"is_string": isString,
+	"is_number": isNumber,
+	"is_bool":   isBool,
+	"is_array":  isArray,
+	"is_object": isObject,
+	"is_type":   isType,
+
+	"weekday":      weekday,
+	"time_between": timeBetween,
+	"date_between": dateBetween,
+}
+
+// defaultOptionsComparators are the default comparators that make use of
+// the options given on a rule
+var defaultOptionsComparators = map[string]OptionsComparator{
+	"approx_eq":  approxEqual(DefaultEpsilon),
+	"approx_neq": approxNotEqual(DefaultEpsilon),
+	"bucket":     bucket,
+}
+
+// defaultCapturers are the default comparators that capture values
+var defaultCapturers = map[string]Capturer{
+	"regex": regexComparer{},
+}
+
+// defaultComparers will return a new map with all of the default comparators
+// that a new engine should include
+func defaultComparers() map[string]Comparer {
+	comps := make(map[string]Comparer, len(defaultComparators)+len(defaultOptionsComparators)+len(defaultCapturers))
+	for name, c := range defaultComparators {
+		comps[name] = c
+	}
+	for name, c := range defaultOptionsComparators {
+		comps[name] = c
+	}
+	for name, c := range defaultCapturers {
+		comps[name] = c
+	}
+	for name := range epsilonComparers {
+		comps[name] = epsilonComparer{comps[name]}
+	}
+	return comps
+}
+
+// epsilonComparer is one of the default comparators that compare numbers
+// with a tolerance. WithEpsilon only replaces these, so that it leaves the
+// comparators an engine was given alone.
+type epsilonComparer struct {
+	Comparer
+}
+
+// epsilonComparers will make the default comparators that compare numbers
+// with a tolerance, along with their description, for the given epsilon
+var epsilonComparers = map[string]func(epsilon float64) (Comparer, string){
+	"eq": func(epsilon float64) (Comparer, string) {
+		return equalWithin(epsilon), fmt.Sprintf("a == b, numbers within %g", epsilon)
+	},
+	"neq": func(epsilon float64) (Comparer, string) {
+		return notEqualWithin(epsilon), fmt.Sprintf("a != b, numbers within %g are equal", epsilon)
+	},
+	"approx_eq": func(epsilon float64) (Comparer, string) {
+		return approxEqual(epsilon), fmt.Sprintf("the numbers a and b are within a tolerance, %g by default", epsilon)
+	},
+	"approx_neq": func(epsilon float64) (Comparer, string) {
+		return approxNotEqual(epsilon), fmt.Sprintf("the numbers a and b are not within a tolerance, %g by default", epsilon)
+	},
+}
+
+// valueParsers prepare the value of a rule for its comparator when the rule
+// is loaded, so that expensive work like parsing a polygon happens once
+// rather than on every evaluation
+var valueParsers = map[string]func(v interface{}) (interface{}, error){
+	"within_radius":  parseCircle,
+	"within_polygon": parsePolygon,
+
+	"levenshtein_lte": parseFuzzy("levenshtein_lte", true, strings.ToLower),
+	"similarity_gte":  parseFuzzy("similarity_gte", true, strings.ToLower),
+	"soundex_eq":      parseFuzzy("soundex_eq", false, soundex),
+	"metaphone_eq":    parseFuzzy("metaphone_eq", false, metaphone),
+
+	"is_type": parseType,
+	"bucket":  parseBucket,
+
+	"weekday":      parseWeekday,
+	"time_between": parseTimeBetween,
+	"date_between": parseDateBetween,
+}
+
+// presenceComparators are the comparators that are concerned with whether a
+// value is there at all, they run before the nil check in a rule's evaluation
+var presenceComparators = map[string]presenceComparator{
+	"exists":    exists,
+	"notexists": notExists,
+	"isnull":    isNull,
+	"isempty":   isEmpty,
+	"notempty":  notEmpty,
 }
 
 // Rule is a our smallest unit of measure, each rule will be
 // evaluated separately. The comparator is the logical operation to be
 // performed, the path is the path into a map, delimited by '.', and
 // the value is the value that we expect to match the value at the
-// path
+// path. An expression can be given in place of the path to compare a
+// computed number instead, and options are given to the comparator. A
+// rule with a quantifier evaluates a nested rule or composite against
+// the elements of a slice instead. When the path yields many values,
+// match decides whether any or all of them must satisfy the comparator.
 type rule struct {
 	Comparator string      `json:"comparator"`
 	Path       string      `json:"path"`
+	Expr       *expression `json:"expr"`
 	Value      interface{} `json:"value"`
+	Options    Options     `json:"options"`
+	Match      string      `json:"match"`
+	Quantifier *quantifier `json:"-"`
+	target     *target
 }
 
 // MarshalJSON is important because it will put maps back into arrays, we used maps
@@ -43,8 +173,11 @@ type rule struct {
 func (r *rule) MarshalJSON() ([]byte, error) {
 	type unmappedRule struct {
 		Comparator string      `json:"comparator"`
-		Path       string      `json:"path"`
+		Path       string      `json:"path,omitempty"`
+		Expr       *expression `json:"expr,omitempty"`
 		Value      interface{} `json:"value"`
+		Options    Options     `json:"options,omitempty"`
+		Match      string      `json:"match,omitempty"`
 	}
 
 	switch t := r.Value.(type) {
@@ -56,10 +189,21 @@ func (r *rule) MarshalJSON() ([]byte, error) {
 		r.Value = s
 	}
 
+	if r.Quantifier != nil {
+		qr := r.Quantifier.quantifiedRule()
+		qr.Comparator = r.Comparator
+		qr.Value = r.Value
+		qr.Options = r.Options
+		return json.Marshal(qr)
+	}
+
 	umr := unmappedRule{
 		Comparator: r.Comparator,
 		Path:       r.Path,
+		Expr:       r.Expr,
 		Value:      r.Value,
+		Options:    r.Options,
+		Match:      r.Match,
 	}
 
 	return json.Marshal(umr)
@@ -71,7 +215,16 @@ func (r *rule) UnmarshalJSON(data []byte) error {
 	type mapRule struct {
 		Comparator string      `json:"comparator"`
 		Path       string      `json:"path"`
+		Expr       *expression `json:"expr"`
 		Value      interface{} `json:"value"`
+		Options    Options     `json:"options"`
+		Match      string      `json:"match"`
+		Any        string      `json:"any"`
+		All        string      `json:"all"`
+		None       string      `json:"none"`
+		Count      string      `json:"count"`
+		Rule       *rule       `json:"rule"`
+		Composite  *composite  `json:"composite"`
 	}
 
 	var mr mapRule
@@ -80,25 +233,96 @@ func (r *rule) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	switch t := mr.Value.(type) {
-	case []interface{}:
-		var m = make(map[interface{}]struct{})
-		for _, v := range t {
-			m[v] = struct{}{}
+	ref, ok, err := newReference(mr.Value)
+	if err != nil {
+		return err
+	}
+	if ok {
+		mr.Value = ref
+	} else {
+		mr.Value, err = prepareValue(mr.Comparator, mr.Value)
+		if err != nil {
+			return err
 		}
+	}
 
-		mr.Value = m
+	switch mr.Match {
+	case "", MatchAny, MatchAll:
+	default:
+		return fmt.Errorf("unknown match %q", mr.Match)
+	}
+
+	q, err := newQuantifier(quantifiedRule{
+		Any:        mr.Any,
+		All:        mr.All,
+		None:       mr.None,
+		Count:      mr.Count,
+		Rule:       mr.Rule,
+		Composite:  mr.Composite,
+		Comparator: mr.Comparator,
+	})
+	if err != nil {
+		return err
 	}
 
 	*r = rule{
 		Comparator: mr.Comparator,
 		Path:       mr.Path,
+		Expr:       mr.Expr,
 		Value:      mr.Value,
+		Options:    mr.Options,
+		Match:      mr.Match,
+		Quantifier: q,
+	}
+
+	// Compile the path and its transforms once, rather than on every
+	// evaluation
+	if r.Expr == nil && r.Quantifier == nil {
+		r.target, err = compileTarget(r.Path)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// prepareValue will get a value ready for the given comparator, either with
+// the comparator's value parser or by turning lists into maps to provide
+// faster lookups. It will return an error if a list holds objects or arrays,
+// which cannot be in a map.
+func prepareValue(comparator string, v interface{}) (interface{}, error) {
+	if parse, ok := valueParsers[comparator]; ok {
+		return parse(v)
+	}
+
+	switch t := v.(type) {
+	case []interface{}:
+		var m = make(map[interface{}]struct{}, len(t))
+		for _, v := range t {
+			if !hashable(v) {
+				return nil, fmt.Errorf("%s: list value cannot hold %v", comparator, v)
+			}
+			m[v] = struct{}{}
+		}
+		return m, nil
+	case []string:
+		var m = make(map[interface{}]struct{}, len(t))
+		for _, v := range t {
+			m[v] = struct{}{}
+		}
+		return m, nil
+	case []float64:
+		var m = make(map[interface{}]struct{}, len(t))
+		for _, v := range t {
+			m[v] = struct{}{}
+		}
+		return m, nil
+	}
+
+	return v, nil
+}
+
 // Composite is a group of rules that are joined by a logical operator
 // AND or OR. If the operator is AND all of the rules must be true,
 // if the operator is OR, one of the rules must be true.
@@ -111,7 +335,12 @@ type composite struct {
 // true for the engine's evaluate function to return true.
 type Engine struct {
 	Composites  []composite `json:"composites"`
-	comparators map[string]Comparator
+	comparators map[string]Comparer
+	infos       map[string]ComparatorInfo
+	clock       func() time.Time
+	resolvers   *resolverTree
+	slots       map[*path]int
+	cacheSize   int
 }
 
 // NewJSONEngine will create a new engine from it's JSON representation
@@ -121,21 +350,243 @@ func NewJSONEngine(raw json.RawMessage) (Engine, error) {
 	if err != nil {
 		return Engine{}, err
 	}
-	e.comparators = defaultComparators
+	e.comparators = defaultComparers()
+	e.infos = defaultComparatorInfos()
+	e.clock = time.Now
+	e.slots, e.cacheSize = e.cachePaths()
 	return e, nil
 }
 
 // AddComparator will add a new comparator that can be used in the
 // engine's evaluation
 func (e Engine) AddComparator(name string, c Comparator) Engine {
-	e.comparators[name] = c
+	return e.AddComparer(name, c)
+}
+
+// AddComparer will add a new comparator that is given the options of the
+// rule being evaluated, e.g. an OptionsComparator
+func (e Engine) AddComparer(name string, c Comparer) Engine {
+	return e.RegisterComparator(ComparatorInfo{Name: name}, c)
+}
+
+// RegisterComparator will add a new comparator along with its description,
+// which is listed by Comparators and used by Validate
+func (e Engine) RegisterComparator(info ComparatorInfo, c Comparer) Engine {
+	e.comparators[info.Name] = c
+	e.infos[info.Name] = info
 	return e
 }
 
-// Evaluate will ensure all of the composites in the engine are true
+// WithEpsilon will return a copy of the engine that treats two float64 values
+// as equal if they are no more than epsilon apart. It applies to eq and neq,
+// and is the default tolerance of approx_eq and approx_neq. Comparators that
+// were added under those names are left alone, and the engine it is called
+// on is not changed.
+func (e Engine) WithEpsilon(epsilon float64) Engine {
+	comps := make(map[string]Comparer, len(e.comparators))
+	for name, c := range e.comparators {
+		comps[name] = c
+	}
+	infos := make(map[string]ComparatorInfo, len(e.infos))
+	for name, info := range e.infos {
+		infos[name] = info
+	}
+
+	for name, within := range epsilonComparers {
+		if _, ok := comps[name].(epsilonComparer); !ok {
+			continue
+		}
+		c, desc := within(epsilon)
+		comps[name] = epsilonComparer{c}
+		if info, ok := infos[name]; ok {
+			info.Description = desc
+			infos[name] = info
+		}
+	}
+
+	e.comparators = comps
+	e.infos = infos
+	return e
+}
+
+// WithClock will set the clock that the reserved $now path resolves to, it
+// defaults to time.Now
+func (e Engine) WithClock(clock func() time.Time) Engine {
+	e.clock = clock
+	return e
+}
+
+// Evaluate will ensure all of the composites in the engine are true. Rules
+// that refer to parameters are false, use EvaluateWith to supply them.
 func (e Engine) Evaluate(props map[string]interface{}) bool {
+	return e.evaluate(e.newEvaluation(context.Background(), MapSource(props)))
+}
+
+// EvaluateSource will ensure all of the composites in the engine are true
+// for the values in the source, which are only asked for when a rule needs
+// them
+func (e Engine) EvaluateSource(src PropertySource) bool {
+	return e.evaluate(e.newEvaluation(context.Background(), src))
+}
+
+// EvaluateStruct will ensure all of the composites in the engine are true
+// for a struct, or a pointer to one, without turning it into a map first.
+// Paths walk struct fields by their json tags, or their names if they have
+// no tag, and follow pointers, embedded structs, slices and maps. Numbers are
+// compared as float64, like they would be after unmarshaling JSON. Maps of
+// any type are evaluated the same way.
+func (e Engine) EvaluateStruct(v interface{}) bool {
+	if props, ok := v.(map[string]interface{}); ok {
+		return e.Evaluate(props)
+	}
+
+	if !isStruct(v) && reflect.ValueOf(v).Kind() != reflect.Map {
+		return false
+	}
+	return e.EvaluateSource(StructSource(v))
+}
+
+// EvaluateJSON will ensure all of the composites in the engine are true for
+// the JSON object in raw. Rather than decoding the whole document, it skips
+// over everything that the engine's rules do not refer to, so only those
+// parts are checked to be valid JSON. Resolvers are given the whole document,
+// which is decoded if one of them is called. It will return an error if raw
+// is not an object, the parts it decodes are not valid, or a resolver fails.
+func (e Engine) EvaluateJSON(raw []byte) (bool, error) {
+	props, err := extractJSON(raw, e.jsonFields())
+	if err != nil {
+		return false, err
+	}
+
+	ev := e.newEvaluation(context.Background(), MapSource(props))
+	if rs, ok := ev.source.(*resolvingSource); ok {
+		rs.props = JSONSource(raw)
+	}
+	return e.evaluateResolved(ev)
+}
+
+// EvaluateContext will ensure all of the composites in the engine are true
+// like Evaluate, passing ctx to the resolvers it calls. It will return the
+// error of the first resolver that fails, or the context's error if it is
+// done before a resolver is called, and no more resolvers are called after
+// that.
+func (e Engine) EvaluateContext(ctx context.Context, props map[string]interface{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return e.evaluateResolved(e.newEvaluation(ctx, MapSource(props)))
+}
+
+// EvaluateWith will ensure all of the composites in the engine are true,
+// resolving values like {"$param": "max_amount"} from params. It will
+// return an error if a parameter that the engine refers to is not given.
+func (e Engine) EvaluateWith(props map[string]interface{}, params map[string]interface{}) (bool, error) {
+	var missing []string
+	for _, name := range e.Params() {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return false, fmt.Errorf("missing parameters: %s", strings.Join(missing, ", "))
+	}
+
+	ev := e.newEvaluation(context.Background(), MapSource(props))
+	ev.params = params
+	return e.evaluate(ev), nil
+}
+
+// EvaluateCaptures will ensure all of the composites in the engine are true
+// like Evaluate, and also return the values captured by the rules that
+// matched, e.g. the named groups of regex rules. Rules are evaluated in
+// order and stop at the first rule that decides a composite, so only the
+// rules that were evaluated can capture. A later capture replaces an earlier
+// one with the same name.
+func (e Engine) EvaluateCaptures(props map[string]interface{}) (bool, map[string]string) {
+	ev := e.newEvaluation(context.Background(), MapSource(props))
+	ev.captures = make(map[string]string)
+	return e.evaluate(ev), ev.captures
+}
+
+// Params will return the sorted names of all the parameters that the
+// engine's rules refer to
+func (e Engine) Params() []string {
+	seen := make(map[string]struct{})
+	e.walk(func(r rule) {
+		if p, ok := r.Value.(paramValue); ok {
+			seen[string(p)] = struct{}{}
+		}
+	})
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newEvaluation will create a new evaluation of the source with the engine's
+// comparators, clock, resolvers and path cache
+func (e Engine) newEvaluation(ctx context.Context, src PropertySource) *evaluation {
+	if e.resolvers != nil {
+		src = newResolvingSource(ctx, src, e.resolvers)
+	}
+	ev := newEvaluation(src, e.comparators)
+	if e.cacheSize > 0 {
+		ev.cache = make([]cachedValue, e.cacheSize)
+		ev.slots = e.slots
+	}
+	if e.clock != nil {
+		ev.now = e.clock
+	}
+	return ev
+}
+
+// evaluateResolved will evaluate the engine and return the error of the
+// first resolver that failed, if any
+func (e Engine) evaluateResolved(ev *evaluation) (bool, error) {
+	res := e.evaluate(ev)
+	if rs, ok := ev.source.(*resolvingSource); ok && rs.err != nil {
+		return false, rs.err
+	}
+	return res, nil
+}
+
+// walk will call fn with every rule in the engine, including the rules
+// nested in quantifiers
+func (e Engine) walk(fn func(r rule)) {
+	for _, c := range e.Composites {
+		c.walk(fn)
+	}
+}
+
+// walk will call fn with every rule in the composite, including the rules
+// nested in quantifiers
+func (c composite) walk(fn func(r rule)) {
+	for _, r := range c.Rules {
+		r.walk(fn)
+	}
+}
+
+// walk will call fn with the rule and every rule nested in its quantifier
+func (r rule) walk(fn func(r rule)) {
+	fn(r)
+	if r.Quantifier == nil {
+		return
+	}
+	if r.Quantifier.Rule != nil {
+		r.Quantifier.Rule.walk(fn)
+	}
+	if r.Quantifier.Composite != nil {
+		r.Quantifier.Composite.walk(fn)
+	}
+}
+
+// evaluate will ensure all of the composites in the engine are true
+func (e Engine) evaluate(ev *evaluation) bool {
 	for _, c := range e.Composites {
-		res := c.evaluate(props, e.comparators)
+		res := c.evaluate(ev)
 		if res == false {
 			return false
 		}
@@ -146,11 +597,11 @@ func (e Engine) Evaluate(props map[string]interface{}) bool {
 // Evaluate will ensure all either all of the rules are true, if given
 // the AND operator, or that one of the rules is true if given the OR
 // operator.
-func (c composite) evaluate(props map[string]interface{}, comps map[string]Comparator) bool {
+func (c composite) evaluate(ev *evaluation) bool {
 	switch c.Operator {
 	case OperatorAnd:
 		for _, r := range c.Rules {
-			res := r.evaluate(props, comps)
+			res := r.evaluate(ev)
 			if res == false {
 				return false
 			}
@@ -158,7 +609,7 @@ func (c composite) evaluate(props map[string]interface{}, comps map[string]Compa
 		return true
 	case OperatorOr:
 		for _, r := range c.Rules {
-			res := r.evaluate(props, comps)
+			res := r.evaluate(ev)
 			if res == true {
 				return true
 			}
@@ -170,17 +621,144 @@ func (c composite) evaluate(props map[string]interface{}, comps map[string]Compa
 }
 
 // Evaluate will return true if the rule is true, false otherwise
-func (r rule) evaluate(props map[string]interface{}, comps map[string]Comparator) bool {
+func (r rule) evaluate(ev *evaluation) bool {
+	if r.Quantifier != nil {
+		return r.Quantifier.evaluate(r, ev)
+	}
+
+	if r.Expr != nil {
+		x, ok := r.Expr.eval(ev)
+		if !ok {
+			return r.compare(ev, nil, false)
+		}
+		return r.compare(ev, x, true)
+	}
+
+	t := r.target
+	if t == nil {
+		// Rules that were not loaded from JSON have no compiled path, plain
+		// paths are looked up without one
+		if plainPath(r.Path) && r.Path != PathNow {
+			val, found := ev.source.Get(r.Path)
+			return r.compare(ev, val, found)
+		}
+
+		var err error
+		t, err = compileTarget(r.Path)
+		if err != nil {
+			return r.compare(ev, nil, false)
+		}
+	}
+
+	if t.path != nil && t.path.multi {
+		return r.compareAll(ev, t, ev.values(t.path))
+	}
+
+	var val interface{}
+	var found bool
+	if t.now {
+		val, found = ev.now(), true
+	} else {
+		val, found = ev.lookup(t.path)
+	}
+	val, found = t.transform(val, found)
+	return r.compare(ev, val, found)
+}
+
+// compareAll will compare each of the values a path yields after the
+// target's transforms, with any of them or all of them needing to match
+// depending on the rule's match. A path that yields nothing is treated like
+// a missing value.
+func (r rule) compareAll(ev *evaluation, t *target, vals []interface{}) bool {
+	if len(vals) == 0 {
+		return r.compare(ev, nil, false)
+	}
+
+	all := r.Match == MatchAll
+	for _, val := range vals {
+		val, found := t.transform(val, true)
+		if r.compare(ev, val, found) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// compare will apply the rule's comparator to a single value
+func (r rule) compare(ev *evaluation, val interface{}, found bool) bool {
+	// Presence comparators need to see missing and null values
+	if pc, ok := presenceComparators[r.Comparator]; ok {
+		return pc(val, found)
+	}
+
 	// Make sure we can get a value from the props
-	val := pluck(props, r.Path)
 	if val == nil {
 		return false
 	}
 
-	comp, ok := comps[r.Comparator]
+	comp, ok := ev.comps[r.Comparator]
 	if !ok {
 		return false
 	}
 
-	return comp(val, r.Value)
+	value, ok := r.value(ev)
+	if !ok {
+		return false
+	}
+
+	if c, ok := comp.(Capturer); ok && ev.captures != nil {
+		matched, captures := c.Capture(val, value, r.Options)
+		if matched {
+			for k, v := range captures {
+				ev.captures[k] = v
+			}
+		}
+		return matched
+	}
+
+	return comp.Compare(val, value, r.Options)
+}
+
+// compiledTarget will return what the rule compares, compiling it if the
+// rule was not loaded from JSON. It is nil for rules that compare an
+// expression or quantify.
+func (r rule) compiledTarget() (*target, error) {
+	if r.target != nil || r.Expr != nil || r.Quantifier != nil {
+		return r.target, nil
+	}
+	return compileTarget(r.Path)
+}
+
+// value will return the value the rule compares against, resolving it from
+// the props if it refers to another path or is an expression, or from the
+// parameters of the evaluation if it refers to a parameter
+func (r rule) value(ev *evaluation) (interface{}, bool) {
+	var val interface{}
+	switch t := r.Value.(type) {
+	case *pathValue:
+		val, _ = ev.lookup(t.path)
+		if val == nil {
+			return nil, false
+		}
+	case paramValue:
+		val = ev.params[string(t)]
+		if val == nil {
+			return nil, false
+		}
+	case *exprValue:
+		x, ok := t.expr.eval(ev)
+		if !ok {
+			return nil, false
+		}
+		return x, true
+	default:
+		return r.Value, true
+	}
+
+	val, err := prepareValue(r.Comparator, val)
+	if err != nil {
+		return nil, false
+	}
+
+	return val, true
 }
@@ -7,8 +7,8 @@ import (
 )
 
 func TestRule_evaluate(t *testing.T) {
-	comparators := map[string]Comparator{
-		"eq": equal,
+	comparators := map[string]Comparer{
+		"eq": Comparator(equal),
 	}
 	props := map[string]interface{}{
 		"first_name": "Trevor",
@@ -19,7 +19,7 @@ func TestRule_evaluate(t *testing.T) {
 			Path:       "first_name",
 			Value:      "Trevor",
 		}
-		res := r.evaluate(props, comparators)
+		res := r.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != true {
 			t.Fatal("expected rule to be true")
 		}
@@ -31,7 +31,7 @@ func TestRule_evaluate(t *testing.T) {
 			Path:       "email",
 			Value:      "Trevor",
 		}
-		res := r.evaluate(props, comparators)
+		res := r.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != false {
 			t.Fatal("expected rule to be false")
 		}
@@ -43,7 +43,7 @@ func TestRule_evaluate(t *testing.T) {
 			Path:       "name",
 			Value:      func() {},
 		}
-		res := r.evaluate(props, comparators)
+		res := r.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != false {
 			t.Fatal("expected rule to be false")
 		}
@@ -55,13 +55,52 @@ func TestRule_evaluate(t *testing.T) {
 			Path:       "name",
 			Value:      "Trevor",
 		}
-		res := r.evaluate(props, comparators)
+		res := r.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != false {
 			t.Fatal("expected rule to be false")
 		}
 	})
 }
 
+func TestRule_evaluatePresence(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "Trevor",
+			"email": nil,
+			"tags":  []interface{}{},
+		},
+	}
+
+	cases := []struct {
+		comparator string
+		path       string
+		expected   bool
+	}{
+		{comparator: "exists", path: "user.name", expected: true},
+		{comparator: "exists", path: "user.email", expected: true},
+		{comparator: "exists", path: "user.phone", expected: false},
+		{comparator: "notexists", path: "user.phone", expected: true},
+		{comparator: "notexists", path: "user.email", expected: false},
+		{comparator: "isnull", path: "user.email", expected: true},
+		{comparator: "isnull", path: "user.phone", expected: false},
+		{comparator: "isempty", path: "user.tags", expected: true},
+		{comparator: "isempty", path: "user.phone", expected: true},
+		{comparator: "notempty", path: "user.name", expected: true},
+		{comparator: "notempty", path: "user.tags", expected: false},
+	}
+
+	for i, c := range cases {
+		r := rule{
+			Comparator: c.comparator,
+			Path:       c.path,
+		}
+		res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %v, got %v", i, c.expected, res)
+		}
+	}
+}
+
 func BenchmarkRule_evaluate(b *testing.B) {
 	r := rule{
 		Comparator: "unit",
@@ -71,15 +110,15 @@ func BenchmarkRule_evaluate(b *testing.B) {
 	props := map[string]interface{}{
 		"name": "Trevor",
 	}
-	comps := map[string]Comparator{
-		"unit": func(a, b interface{}) bool {
+	comps := map[string]Comparer{
+		"unit": Comparator(func(a, b interface{}) bool {
 			return true
-		},
+		}),
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		r.evaluate(props, comps)
+		r.evaluate(newEvaluation(MapSource(props), comps))
 	}
 }
 
@@ -120,8 +159,8 @@ func TestRule_MarshalJSON(t *testing.T) {
 }
 
 func TestComposite_evaluate(t *testing.T) {
-	comparators := map[string]Comparator{
-		"eq": equal,
+	comparators := map[string]Comparer{
+		"eq": Comparator(equal),
 	}
 	props := map[string]interface{}{
 		"name": "Trevor",
@@ -144,7 +183,7 @@ func TestComposite_evaluate(t *testing.T) {
 				},
 			},
 		}
-		res := c.evaluate(props, comparators)
+		res := c.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != true {
 			t.Fatal("expected composite to be true")
 		}
@@ -166,7 +205,7 @@ func TestComposite_evaluate(t *testing.T) {
 				},
 			},
 		}
-		res := c.evaluate(props, comparators)
+		res := c.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != true {
 			t.Fatal("expected composite to be true")
 		}
@@ -188,7 +227,7 @@ func TestComposite_evaluate(t *testing.T) {
 				},
 			},
 		}
-		res := c.evaluate(props, comparators)
+		res := c.evaluate(newEvaluation(MapSource(props), comparators))
 		if res != false {
 			t.Fatal("expected composite to be true")
 		}
@@ -210,15 +249,15 @@ func BenchmarkComposite_evaluate(b *testing.B) {
 	props := map[string]interface{}{
 		"name": "Trevor",
 	}
-	comps := map[string]Comparator{
-		"unit": func(a, b interface{}) bool {
+	comps := map[string]Comparer{
+		"unit": Comparator(func(a, b interface{}) bool {
 			return true
-		},
+		}),
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		c.evaluate(props, comps)
+		c.evaluate(newEvaluation(MapSource(props), comps))
 	}
 }
 
@@ -292,6 +331,14 @@ func TestNewJSONEngine(t *testing.T) {
 			t.Fatal("expected list to be transformed to map")
 		}
 	})
+
+	t.Run("list of objects", func(t *testing.T) {
+		j := []byte(`{"composites":[{"operator":"and","rules":[{"comparator":"oneof","path":"first_name","value":[{"name":"Trevor"}]}]}]}`)
+		_, err := NewJSONEngine(j)
+		if err == nil {
+			t.Fatal("expected an error for a list of objects")
+		}
+	})
 }
 
 func TestEngineEvaluate(t *testing.T) {
@@ -374,6 +421,44 @@ func TestEngineEvaluate(t *testing.T) {
 			t.Fatal("expected engine to pass")
 		}
 	})
+
+	t.Run("1 composite, set comparators", func(t *testing.T) {
+		props := map[string]interface{}{
+			"user": map[string]interface{}{
+				"roles": []interface{}{"admin", "billing"},
+			},
+		}
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"containsall","path":"user.roles","value":["admin","billing"]},{"comparator":"subsetof","path":"user.roles","value":["admin","billing","support"]},{"comparator":"containsnone","path":"user.roles","value":["banned"]}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := e.Evaluate(props)
+		if res != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
+
+	t.Run("1 composite, set comparators with object elements", func(t *testing.T) {
+		props := map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"a": float64(1)},
+				"x",
+			},
+		}
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"containsany","path":"items","value":["x"]},{"comparator":"containsall","path":"items","value":["x"]},{"comparator":"intersects","path":"items","value":["x"]}]},{"operator":"or","rules":[{"comparator":"subsetof","path":"items","value":["x"]},{"comparator":"supersetof","path":"items","value":["y"]},{"comparator":"oneof","path":"items.0","value":["x"]}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := e.Evaluate(props)
+		if res != false {
+			t.Fatal("expected engine to fail")
+		}
+		e.Composites = e.Composites[:1]
+		res = e.Evaluate(props)
+		if res != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
 }
 
 func BenchmarkEngine_Evaluate(b *testing.B) {
@@ -391,3 +476,308 @@ func BenchmarkEngine_Evaluate(b *testing.B) {
 		e.Evaluate(props)
 	}
 }
+
+func TestEngine_EvaluateWith(t *testing.T) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"lte","path":"payment.amount","value":{"$param":"max_amount"}},{"any":"payment.items","rule":{"comparator":"oneof","path":"category","value":{"$param":"categories"}}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := map[string]interface{}{
+		"payment": map[string]interface{}{
+			"amount": float64(250),
+			"items": []interface{}{
+				map[string]interface{}{"category": "books"},
+			},
+		},
+	}
+
+	t.Run("params", func(t *testing.T) {
+		params := []string{"categories", "max_amount"}
+		if !reflect.DeepEqual(e.Params(), params) {
+			t.Fatalf("expected params to be %v, got %v", params, e.Params())
+		}
+	})
+
+	t.Run("tenant a", func(t *testing.T) {
+		res, err := e.EvaluateWith(props, map[string]interface{}{
+			"max_amount": float64(500),
+			"categories": []interface{}{"books", "music"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
+
+	t.Run("tenant b", func(t *testing.T) {
+		res, err := e.EvaluateWith(props, map[string]interface{}{
+			"max_amount": float64(100),
+			"categories": []string{"books"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != false {
+			t.Fatal("expected engine to fail")
+		}
+	})
+
+	t.Run("missing params", func(t *testing.T) {
+		_, err := e.EvaluateWith(props, map[string]interface{}{
+			"categories": []string{"books"},
+		})
+		if err == nil || err.Error() != "missing parameters: max_amount" {
+			t.Fatalf("expected missing parameter error, got %v", err)
+		}
+	})
+
+	t.Run("missing params, engine not from json", func(t *testing.T) {
+		_, err := Engine{Composites: e.Composites}.EvaluateWith(props, nil)
+		if err == nil || err.Error() != "missing parameters: categories, max_amount" {
+			t.Fatalf("expected missing parameter error, got %v", err)
+		}
+	})
+
+	t.Run("missing params, composites changed", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Composites = append(e.Composites, composite{
+			Operator: "and",
+			Rules:    []rule{{Comparator: "lte", Path: "payment.amount", Value: paramValue("max")}},
+		})
+
+		_, err = e.EvaluateWith(props, nil)
+		if err == nil || err.Error() != "missing parameters: max" {
+			t.Fatalf("expected missing parameter error, got %v", err)
+		}
+	})
+
+	t.Run("evaluate without params", func(t *testing.T) {
+		if e.Evaluate(props) != false {
+			t.Fatal("expected engine to fail")
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"lte","path":"payment.amount","value":{"$param":"max_amount"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+}
+
+func BenchmarkEngine_EvaluateWith(b *testing.B) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"lte","path":"payment.amount","value":{"$param":"max_amount"}},{"comparator":"gte","path":"payment.amount","value":{"$param":"min_amount"}}]}]}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	props := map[string]interface{}{
+		"payment": map[string]interface{}{
+			"amount": float64(250),
+		},
+	}
+	params := map[string]interface{}{
+		"max_amount": float64(500),
+		"min_amount": float64(100),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.EvaluateWith(props, params)
+	}
+}
+
+func TestRuleOptions(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"email": "Trevor@Example.com",
+			"age":   float64(23),
+		},
+	}
+
+	t.Run("built in", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"regex","path":"user.email","value":"@example\\.com$","options":{"case_insensitive":true}}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Evaluate(props) != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"between","path":"user.age","value":null,"options":{"min":18,"max":30}}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e = e.AddComparer("between", OptionsComparator(func(a, b interface{}, options Options) bool {
+			return greaterThanEqual(a, options["min"]) && lessThanEqual(a, options["max"])
+		}))
+		if e.Evaluate(props) != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"regex","path":"user.email","value":"@example","options":{"case_insensitive":true}},{"count":"user.tags","rule":{"comparator":"eq","value":"a"},"comparator":"gte","value":1,"options":{"x":1}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+
+	t.Run("engines do not share comparators", func(t *testing.T) {
+		a, err := NewJSONEngine(json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewJSONEngine(json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.AddComparator("only-a", equal)
+		if b.comparators["only-a"] != nil {
+			t.Fatal("expected comparator to only be added to one engine")
+		}
+	})
+}
+
+func TestEngine_WithEpsilon(t *testing.T) {
+	props := map[string]interface{}{
+		"total": sum,
+	}
+
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"total","value":0.3}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Evaluate(props) != false {
+		t.Fatal("expected exact eq to fail")
+	}
+
+	e = e.WithEpsilon(1e-9)
+	if e.Evaluate(props) != true {
+		t.Fatal("expected eq within epsilon to pass")
+	}
+
+	e, err = NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"approx_eq","path":"total","value":0.31}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Evaluate(props) != false {
+		t.Fatal("expected approx_eq with default epsilon to fail")
+	}
+	if e.WithEpsilon(0.1).Evaluate(props) != true {
+		t.Fatal("expected approx_eq with engine epsilon to pass")
+	}
+
+	t.Run("copies the engine", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"total","value":0.3}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		within := e.WithEpsilon(1e-6)
+		if within.Evaluate(props) != true {
+			t.Fatal("expected eq within epsilon to pass")
+		}
+		if e.Evaluate(props) != false {
+			t.Fatal("expected the original engine to still be exact")
+		}
+		if within.info("eq").Description == e.info("eq").Description {
+			t.Fatal("expected the description of eq to mention the epsilon")
+		}
+		if within.WithEpsilon(1e-20).Evaluate(props) != false {
+			t.Fatal("expected a smaller epsilon to replace the larger one")
+		}
+	})
+
+	t.Run("keeps custom comparators", func(t *testing.T) {
+		e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"total","value":0.3}]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e = e.AddComparator("eq", func(a, b interface{}) bool { return false })
+		e = e.WithEpsilon(1)
+		if e.Evaluate(props) != false {
+			t.Fatal("expected the custom eq to be kept")
+		}
+		if e.info("eq").Description != "" {
+			t.Fatal("expected the description of the custom eq to be kept")
+		}
+	})
+}
+
+func TestEngine_EvaluateCaptures(t *testing.T) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"regex","path":"user.email","value":"@(?P<domain>[^@]+)$"},{"comparator":"regex","path":"user.phone","value":"^\\+(?P<country>\\d+) "}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("matched", func(t *testing.T) {
+		props := map[string]interface{}{
+			"user": map[string]interface{}{
+				"email": "trevor@example.com",
+				"phone": "+1 555 555 5555",
+			},
+		}
+		res, captures := e.EvaluateCaptures(props)
+		if res != true {
+			t.Fatal("expected engine to pass")
+		}
+		expected := map[string]string{"domain": "example.com", "country": "1"}
+		if !reflect.DeepEqual(captures, expected) {
+			t.Fatalf("expected captures to be %v, got %v", expected, captures)
+		}
+	})
+
+	t.Run("not matched", func(t *testing.T) {
+		props := map[string]interface{}{
+			"user": map[string]interface{}{
+				"email": "trevor@example.com",
+				"phone": "555 555 5555",
+			},
+		}
+		res, captures := e.EvaluateCaptures(props)
+		if res != false {
+			t.Fatal("expected engine to fail")
+		}
+		if captures["country"] != "" {
+			t.Fatal("expected country to not be captured")
+		}
+	})
+
+	t.Run("evaluate", func(t *testing.T) {
+		props := map[string]interface{}{
+			"user": map[string]interface{}{
+				"email": "trevor@example.com",
+				"phone": "+1 555 555 5555",
+			},
+		}
+		if e.Evaluate(props) != true {
+			t.Fatal("expected engine to pass")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package grules
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// PropertySource is where an engine gets the values that its rules compare.
+// Get is given a path, e.g. "user.name" or `headers["x.forwarded.for"]`, and
+// will return the value at the path and whether the path exists. The path
+// "$" is the whole source. Paths are given in the form they print in, so a
+// source sees the same path the same way whether it comes from a rule's
+// path, a {"$path": ...} value or an expression, e.g. "a.0" for "a[0]". A
+// source can load values lazily or from a cache, Get is called every time a
+// rule needs a value that the evaluation has not cached.
+//
+// When a path yields many values, e.g. "orders.*.status", Get is given the
+// part of the path before the first wildcard or filter, and the rest of the
+// path is walked in the value it returns.
+type PropertySource interface {
+	Get(path string) (interface{}, bool)
+}
+
+// rootedSource is a PropertySource backed by a single value that compiled
+// paths can walk directly, rather than going through Get
+type rootedSource interface {
+	PropertySource
+	root() interface{}
+}
+
+// pathSource is a PropertySource that looks up compiled paths itself
+type pathSource interface {
+	PropertySource
+	lookupPath(p *path) (interface{}, bool)
+	pathValues(p *path) []interface{}
+}
+
+// MapSource is a PropertySource backed by props, it is what Evaluate uses
+type MapSource map[string]interface{}
+
+// Get will return the value at the path in the props
+func (m MapSource) Get(path string) (interface{}, bool) {
+	if path == "$" {
+		return map[string]interface{}(m), true
+	}
+	return lookup(m, path)
+}
+
+func (m MapSource) root() interface{} {
+	return map[string]interface{}(m)
+}
+
+// valueSource is a PropertySource backed by a struct, or any other value
+// that paths can walk, like a map of another type than MapSource
+type valueSource struct {
+	v interface{}
+}
+
+// StructSource will return a PropertySource backed by the struct v, or a
+// pointer to one, see Engine.EvaluateStruct for how paths walk it
+func StructSource(v interface{}) PropertySource {
+	return valueSource{v: v}
+}
+
+// Get will return the value at the path in the value
+func (s valueSource) Get(path string) (interface{}, bool) {
+	return lookupValue(s.v, path)
+}
+
+func (s valueSource) root() interface{} {
+	return s.v
+}
+
+// jsonSource is a PropertySource backed by a JSON document that is decoded
+// the first time a value is needed
+type jsonSource struct {
+	raw  []byte
+	once sync.Once
+	doc  interface{}
+}
+
+// JSONSource will return a PropertySource backed by the JSON document in
+// raw, it is not decoded unless a rule needs a value from it. A document that
+// is not valid JSON has no values.
+func JSONSource(raw []byte) PropertySource {
+	return &jsonSource{raw: raw}
+}
+
+// Get will return the value at the path in the document
+func (s *jsonSource) Get(path string) (interface{}, bool) {
+	return lookupValue(s.root(), path)
+}
+
+func (s *jsonSource) root() interface{} {
+	s.once.Do(func() {
+		if err := json.Unmarshal(s.raw, &s.doc); err != nil {
+			s.doc = nil
+		}
+	})
+	return s.doc
+}
+
+// sourceLookup will return the value at the compiled path p in src, a path
+// that yields many values returns the first of them
+func sourceLookup(src PropertySource, p *path) (interface{}, bool) {
+	if ps, ok := src.(pathSource); ok {
+		return ps.lookupPath(p)
+	}
+	if p.multi {
+		vals := sourceValues(src, p)
+		if len(vals) == 0 {
+			return nil, false
+		}
+		return vals[0], true
+	}
+	if r, ok := src.(rootedSource); ok {
+		return p.lookupFrom(r.root())
+	}
+	return src.Get(p.key)
+}
+
+// sourceValues will return every value the compiled path p yields in src
+func sourceValues(src PropertySource, p *path) []interface{} {
+	if ps, ok := src.(pathSource); ok {
+		return ps.pathValues(p)
+	}
+	if r, ok := src.(rootedSource); ok {
+		return p.valuesFrom(r.root(), p.segments)
+	}
+
+	// Get the value before the first wildcard or filter, and walk the rest
+	// of the path in it
+	head := 0
+	for head < len(p.segments) && p.segments[head].kind == segmentKey {
+		head++
+	}
+	val, ok := src.Get(formatSegments(p.segments[:head], true))
+	if !ok {
+		return nil
+	}
+	return p.valuesFrom(val, p.segments[head:])
+}
+
+// elementSource will return the source for a single element of a slice,
+// maps and structs are walked by the paths of nested rules, and elements
+// that are not maps or structs are made available under the empty path
+func elementSource(e interface{}) PropertySource {
+	if m, ok := e.(map[string]interface{}); ok {
+		return MapSource(m)
+	}
+	if isStruct(e) || reflect.ValueOf(e).Kind() == reflect.Map {
+		return valueSource{v: e}
+	}
+	return MapSource{"": e}
+}
@@ -0,0 +1,138 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// countingSource is a PropertySource that loads values from a map and counts
+// the paths it was asked for
+type countingSource struct {
+	values map[string]interface{}
+	gets   map[string]int
+}
+
+func (s *countingSource) Get(path string) (interface{}, bool) {
+	s.gets[path]++
+	val, ok := s.values[path]
+	return val, ok
+}
+
+func TestPropertySources(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "Trevor",
+			"tags": []interface{}{"a", "b"},
+		},
+	}
+	raw := []byte(`{"user":{"name":"Trevor","tags":["a","b"]}}`)
+	type user struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	type doc struct {
+		User user `json:"user"`
+	}
+
+	sources := map[string]PropertySource{
+		"map":    MapSource(props),
+		"struct": StructSource(doc{User: user{Name: "Trevor", Tags: []string{"a", "b"}}}),
+		"json":   JSONSource(raw),
+	}
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			val, ok := src.Get("user.name")
+			if !ok || val != "Trevor" {
+				t.Fatalf("expected user.name to be Trevor, got %v", val)
+			}
+			val, ok = src.Get(`$["user"].tags[-1]`)
+			if !ok || val != "b" {
+				t.Fatalf("expected the last tag to be b, got %v", val)
+			}
+			if _, ok := src.Get("user.email"); ok {
+				t.Fatal("expected user.email to be missing")
+			}
+			if _, ok := src.Get("$"); !ok {
+				t.Fatal("expected the root to be found")
+			}
+		})
+	}
+
+	if _, ok := JSONSource([]byte(`{`)).Get("user"); ok {
+		t.Fatal("expected invalid JSON to have no values")
+	}
+}
+
+func TestEngine_EvaluateSource(t *testing.T) {
+	j := `{"composites":[{"operator":"and","rules":[
+		{"comparator":"eq","path":"account.country","value":"US"},
+		{"comparator":"gt","path":"account.balance","value":100},
+		{"comparator":"eq","path":"orders.*.status","value":"shipped"},
+		{"comparator":"gt","expr":"account.balance - account.hold","value":50}
+	]}]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &countingSource{
+		values: map[string]interface{}{
+			"account.country": "US",
+			"account.balance": float64(150),
+			"account.hold":    float64(20),
+			"orders": []interface{}{
+				map[string]interface{}{"status": "pending"},
+				map[string]interface{}{"status": "shipped"},
+			},
+		},
+		gets: make(map[string]int),
+	}
+	if !e.EvaluateSource(src) {
+		t.Fatal("expected engine to be true")
+	}
+	if src.gets["orders"] != 1 {
+		t.Fatalf("expected the part of the path before the wildcard to be loaded, got %v", src.gets)
+	}
+
+	// Rules after the first false rule in an and composite never ask for
+	// their values
+	src.values["account.country"] = "CA"
+	src.gets = make(map[string]int)
+	if e.EvaluateSource(src) {
+		t.Fatal("expected engine to be false")
+	}
+	if len(src.gets) != 1 {
+		t.Fatalf("expected only account.country to be loaded, got %v", src.gets)
+	}
+}
+
+func TestEngine_EvaluateSourcePathForms(t *testing.T) {
+	j := `{"composites":[
+		{"operator":"and","rules":[{"comparator":"lt","path":"spend[0]","value":{"$path":"limits[0]"}}]},
+		{"operator":"and","rules":[{"comparator":"lt","expr":"spend.0 * 2","value":{"$expr":"limits.0 * 2"}}]},
+		{"operator":"and","rules":[{"comparator":"gte","path":"$.limits.0","value":100}]}
+	]}`
+	e, err := NewJSONEngine(json.RawMessage(j))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &countingSource{
+		values: map[string]interface{}{
+			"spend.0":  float64(50),
+			"limits.0": float64(100),
+		},
+		gets: make(map[string]int),
+	}
+	if !e.EvaluateSource(src) {
+		t.Fatal("expected engine to be true")
+	}
+
+	// Rule paths, $path values and expressions ask for the same path the
+	// same way, and the evaluation only asks once
+	expected := map[string]int{"spend.0": 1, "limits.0": 1}
+	if len(src.gets) != len(expected) || src.gets["spend.0"] != 1 || src.gets["limits.0"] != 1 {
+		t.Fatalf("expected gets to be %v, got %v", expected, src.gets)
+	}
+}
@@ -0,0 +1,189 @@
+package grules
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structPlan is how the fields of a struct type are found by name. Fields
+// are named by their json tag, or by their Go name if they have none, and
+// the fields of embedded structs are promoted like encoding/json does.
+type structPlan struct {
+	names  []string
+	fields map[string][]int
+}
+
+// structPlans caches the plan of every struct type that has been evaluated
+var structPlans sync.Map
+
+// planOf will return the plan for the struct type t, making it the first
+// time the type is seen
+func planOf(t reflect.Type) *structPlan {
+	if p, ok := structPlans.Load(t); ok {
+		return p.(*structPlan)
+	}
+	p, _ := structPlans.LoadOrStore(t, newStructPlan(t))
+	return p.(*structPlan)
+}
+
+// newStructPlan will find the fields of t, a field at a shallower depth of
+// embedding hides fields with the same name deeper down, and fields at the
+// same depth with the same name hide each other, and every field with that
+// name deeper down, unless one of them is tagged
+func newStructPlan(t reflect.Type) *structPlan {
+	type level struct {
+		t     reflect.Type
+		index []int
+	}
+	type candidate struct {
+		index  []int
+		tagged bool
+	}
+
+	p := &structPlan{fields: make(map[string][]int)}
+	hidden := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	current := []level{{t: t}}
+	for len(current) > 0 {
+		var next []level
+		var order []string
+		found := make(map[string][]candidate)
+		for _, l := range current {
+			if visited[l.t] {
+				continue
+			}
+			visited[l.t] = true
+
+			for i := 0; i < l.t.NumField(); i++ {
+				f := l.t.Field(i)
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// The exported fields of unexported embedded structs are
+				// still promoted
+				embedded := f.Anonymous && ft.Kind() == reflect.Struct
+				if !f.IsExported() && !embedded {
+					continue
+				}
+				tag := f.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, _, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), l.index...), i)
+
+				if embedded && name == "" {
+					next = append(next, level{t: ft, index: index})
+					continue
+				}
+				if !f.IsExported() {
+					continue
+				}
+
+				tagged := name != ""
+				if !tagged {
+					name = f.Name
+				}
+				if _, ok := p.fields[name]; ok || hidden[name] {
+					continue
+				}
+				if _, ok := found[name]; !ok {
+					order = append(order, name)
+				}
+				found[name] = append(found[name], candidate{index: index, tagged: tagged})
+			}
+		}
+
+		for _, name := range order {
+			var winner []int
+			var tagged int
+			for _, c := range found[name] {
+				if c.tagged {
+					winner = c.index
+					tagged++
+				}
+			}
+			switch {
+			case len(found[name]) == 1:
+				winner = found[name][0].index
+			case tagged != 1:
+				hidden[name] = true
+				continue
+			}
+			p.names = append(p.names, name)
+			p.fields[name] = winner
+		}
+
+		current = next
+	}
+
+	return p
+}
+
+// field will return the value of the field with the given name in the struct
+// rv, it is not found if the name is unknown or an embedded pointer on the
+// way to it is nil
+func (p *structPlan) field(rv reflect.Value, name string) (interface{}, bool) {
+	index, ok := p.fields[name]
+	if !ok {
+		return nil, false
+	}
+
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return nil, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return plainValue(rv), true
+}
+
+// isStruct will return true if v is a struct, or a non nil pointer to one
+func isStruct(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	return rv.Kind() == reflect.Struct
+}
+
+// plainValue will return the value in rv the way it would be after a round
+// trip through JSON, pointers are followed and numbers become float64, so
+// that rules compare struct fields like they compare unmarshaled props
+func plainValue(rv reflect.Value) interface{} {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Invalid:
+		return nil
+	}
+	return rv.Interface()
+}
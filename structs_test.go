@@ -0,0 +1,211 @@
+package grules
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type testAudit struct {
+	CreatedBy string `json:"created_by"`
+	Version   int    `json:"version"`
+}
+
+type testStatus string
+
+type testItem struct {
+	SKU string  `json:"sku"`
+	Qty int     `json:"qty"`
+	Tax float32 `json:"tax,omitempty"`
+}
+
+type testUser struct {
+	testAudit
+	*testOwner
+	Name      string            `json:"name"`
+	Age       int               `json:"age"`
+	Email     *string           `json:"email"`
+	Status    testStatus        `json:"status"`
+	Address   *testAddress      `json:"address"`
+	Addresses []testAddress     `json:"addresses"`
+	Items     []testItem        `json:"items"`
+	Labels    map[string]string `json:"labels"`
+	Tags      []string          `json:"tags"`
+	Password  string            `json:"-"`
+	Untagged  bool
+	secret    string
+}
+
+type testOwner struct {
+	Team string `json:"team"`
+}
+
+func TestEngine_EvaluateStruct(t *testing.T) {
+	email := "trevor@example.com"
+	user := testUser{
+		testAudit: testAudit{CreatedBy: "admin", Version: 3},
+		Name:      "Trevor",
+		Age:       30,
+		Email:     &email,
+		Status:    "active",
+		Address:   &testAddress{City: "Atlanta", Zip: "30303"},
+		Addresses: []testAddress{{City: "Atlanta"}, {City: "New York"}},
+		Items:     []testItem{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 3}},
+		Labels:    map[string]string{"tier": "gold", "x.y": "z"},
+		Tags:      []string{"vip"},
+		Password:  "hunter2",
+		Untagged:  true,
+		secret:    "shh",
+	}
+
+	cases := []struct {
+		rule     string
+		expected bool
+	}{
+		{rule: `{"comparator":"eq","path":"name","value":"Trevor"}`, expected: true},
+		{rule: `{"comparator":"gte","path":"age","value":18}`, expected: true},
+		{rule: `{"comparator":"eq","path":"age","value":30}`, expected: true},
+		{rule: `{"comparator":"eq","path":"email","value":"trevor@example.com"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"status","value":"active"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"address.city","value":"Atlanta"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"addresses.-1.city","value":"New York"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"labels.tier","value":"gold"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"labels[\"x.y\"]","value":"z"}`, expected: true},
+		{rule: `{"comparator":"contains","path":"tags","value":"vip"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"created_by","value":"admin"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"version","value":3}`, expected: true},
+		{rule: `{"comparator":"eq","path":"Untagged","value":true}`, expected: true},
+		{rule: `{"comparator":"exists","path":"Password"}`, expected: false},
+		{rule: `{"comparator":"exists","path":"secret"}`, expected: false},
+		{rule: `{"comparator":"exists","path":"team"}`, expected: false},
+		{rule: `{"comparator":"notexists","path":"nickname"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"items.*.sku","value":"b"}`, expected: true},
+		{rule: `{"comparator":"gt","path":"$.items[?(@.qty > 1)].qty","value":2,"match":"all"}`, expected: true},
+		{rule: `{"any":"items","rule":{"comparator":"eq","path":"sku","value":"a"}}`, expected: true},
+		{rule: `{"count":"items","rule":{"comparator":"gte","path":"qty","value":1},"comparator":"eq","value":2}`, expected: true},
+		{rule: `{"comparator":"gt","expr":"age * 2","value":59}`, expected: true},
+		{rule: `{"comparator":"is_object","path":"address"}`, expected: true},
+		{rule: `{"comparator":"is_object","path":"addresses.0"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"address.*","value":"30303"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"$[*]","value":"Trevor"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"$.addresses[*][*]","value":"New York"}`, expected: true},
+		{rule: `{"comparator":"eq","path":"$.address[?(@ == 'Atlanta')]","value":"Atlanta"}`, expected: true},
+	}
+
+	for _, c := range cases {
+		j := `{"composites":[{"operator":"and","rules":[` + c.rule + `]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := e.EvaluateStruct(user); res != c.expected {
+			t.Fatalf("expected %s to be %v, got %v", c.rule, c.expected, res)
+		}
+		if res := e.EvaluateStruct(&user); res != c.expected {
+			t.Fatalf("expected %s on a pointer to be %v, got %v", c.rule, c.expected, res)
+		}
+	}
+
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"team","value":"core"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.testOwner = &testOwner{Team: "core"}
+	if !e.EvaluateStruct(user) {
+		t.Fatal("expected fields of an embedded pointer to be promoted")
+	}
+	if e.EvaluateStruct((*testUser)(nil)) {
+		t.Fatal("expected a nil pointer to be false")
+	}
+	if e.EvaluateStruct(42) {
+		t.Fatal("expected a number to be false")
+	}
+	if !e.EvaluateStruct(map[string]interface{}{"team": "core"}) {
+		t.Fatal("expected a map to be evaluated like props")
+	}
+	if !e.EvaluateStruct(map[string]string{"team": "core"}) {
+		t.Fatal("expected a typed map to be evaluated")
+	}
+	if e.EvaluateStruct(map[string]string{"team": "web"}) {
+		t.Fatal("expected a typed map to be evaluated")
+	}
+}
+
+func TestNewStructPlan(t *testing.T) {
+	type inner struct {
+		Name  string `json:"name"`
+		Level int    `json:"level"`
+		Code  string
+	}
+	type other struct {
+		Code string
+	}
+	type outer struct {
+		inner
+		other
+		Level string `json:"level"`
+	}
+
+	p := newStructPlan(reflect.TypeOf(outer{}))
+
+	expected := map[string][]int{
+		"level": {2},
+		"name":  {0, 0},
+	}
+	if !reflect.DeepEqual(p.fields, expected) {
+		t.Fatalf("expected fields to be %v, got %v", expected, p.fields)
+	}
+	if planOf(reflect.TypeOf(outer{})) != planOf(reflect.TypeOf(outer{})) {
+		t.Fatal("expected plans to be cached")
+	}
+
+	type zA struct{ X int }
+	type zB struct{ X int }
+	type zDeep struct{ X int }
+	type zMid struct{ zDeep }
+	type zc struct {
+		zA
+		zB
+		zMid
+	}
+	if p := newStructPlan(reflect.TypeOf(zc{})); len(p.fields) != 0 {
+		t.Fatalf("expected fields that conflict to hide the same name deeper down, got %v", p.fields)
+	}
+	if _, ok := lookupValue(zc{zA{1}, zB{2}, zMid{zDeep{3}}}, "X"); ok {
+		t.Fatal("expected X to be ambiguous")
+	}
+}
+
+func BenchmarkEngine_EvaluateStruct(b *testing.B) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"address.city","value":"Atlanta"},{"comparator":"gte","path":"age","value":18}]}]}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	user := testUser{Name: "Trevor", Age: 30, Address: &testAddress{City: "Atlanta"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.EvaluateStruct(user)
+	}
+}
+
+func BenchmarkEngine_EvaluateMarshaledStruct(b *testing.B) {
+	e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"address.city","value":"Atlanta"},{"comparator":"gte","path":"age","value":18}]}]}`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	user := testUser{Name: "Trevor", Age: 30, Address: &testAddress{City: "Atlanta"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw, _ := json.Marshal(user)
+		var props map[string]interface{}
+		json.Unmarshal(raw, &props)
+		e.Evaluate(props)
+	}
+}
@@ -0,0 +1,169 @@
+package grules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Transform changes the value at a rule's path before it is compared, e.g.
+// lower(user.email). It will return false if it does not apply to the value,
+// which the rule then treats as missing.
+type Transform func(v interface{}) (interface{}, bool)
+
+var (
+	transformsMu sync.RWMutex
+
+	// transforms are the transforms a rule's path can use, by name
+	transforms = map[string]Transform{
+		"lower": stringTransform(strings.ToLower),
+		"upper": stringTransform(strings.ToUpper),
+		"trim":  stringTransform(strings.TrimSpace),
+		"len":   length,
+		"year":  timeTransform(time.Time.Year),
+		"month": timeTransform(func(t time.Time) int { return int(t.Month()) }),
+		"day":   timeTransform(time.Time.Day),
+	}
+)
+
+// RegisterTransform will make a transform available to the rules that are
+// loaded after it, registering a name again replaces the transform.
+// Transforms are registered for the whole package rather than per engine,
+// like AddComparator, because a rule's transforms are looked up when it is
+// unmarshaled, before there is an engine to look them up in.
+func RegisterTransform(name string, t Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = t
+}
+
+// lookupTransform will return the transform with the given name
+func lookupTransform(name string) (Transform, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	t, ok := transforms[name]
+	return t, ok
+}
+
+// target is what a rule compares, the value at a compiled path or the
+// engine's clock, with the transforms to apply to it innermost first
+type target struct {
+	path       *path
+	now        bool
+	transforms []Transform
+}
+
+// compileTarget will parse a rule's path, which is either a path or a path
+// wrapped in transforms, e.g. len(trim(user.name))
+func compileTarget(src string) (*target, error) {
+	t := &target{}
+	inner := strings.TrimSpace(src)
+	for {
+		open := strings.IndexByte(inner, '(')
+		if open <= 0 || !strings.HasSuffix(inner, ")") || !isIdent(inner[:open]) {
+			break
+		}
+
+		name := inner[:open]
+		fn, ok := lookupTransform(name)
+		if !ok {
+			return nil, fmt.Errorf("path %q: unknown transform %q", src, name)
+		}
+		t.transforms = append([]Transform{fn}, t.transforms...)
+		inner = strings.TrimSpace(inner[open+1 : len(inner)-1])
+	}
+
+	if inner == PathNow {
+		t.now = true
+		return t, nil
+	}
+
+	var err error
+	t.path, err = compilePath(inner)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// isIdent will return true if s is a transform name, a letter or underscore
+// followed by letters, digits and underscores
+func isIdent(s string) bool {
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// transform will apply the target's transforms to a value that was found
+func (t *target) transform(val interface{}, found bool) (interface{}, bool) {
+	if !found {
+		return nil, false
+	}
+	for _, fn := range t.transforms {
+		var ok bool
+		val, ok = fn(val)
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// stringTransform will return a transform that applies fn to strings
+func stringTransform(fn func(string) string) Transform {
+	return func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		return fn(s), true
+	}
+}
+
+// length will return the number of characters in a string, or the number of
+// elements in a slice or map
+func length(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		return float64(utf8.RuneCountInString(t)), true
+	case []interface{}:
+		return float64(len(t)), true
+	case map[string]interface{}:
+		return float64(len(t)), true
+	case nil:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(rv.Len()), true
+	}
+	return nil, false
+}
+
+// timeTransform will return a transform that applies fn to a time. Strings
+// are taken in their own offset. Numbers of seconds since the Unix epoch,
+// and times in the host's local time zone like the default $now, are taken
+// in UTC, so that the result does not depend on the machine.
+func timeTransform(fn func(t time.Time) int) Transform {
+	return func(v interface{}) (interface{}, bool) {
+		t, ok := toTime(v)
+		if !ok {
+			return nil, false
+		}
+		if _, isString := v.(string); !isString && t.Location() == time.Local {
+			t = t.UTC()
+		}
+		return float64(fn(t)), true
+	}
+}
@@ -0,0 +1,138 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTransforms(t *testing.T) {
+	cases := []struct {
+		name     string
+		in       interface{}
+		expected interface{}
+		ok       bool
+	}{
+		{name: "lower", in: "Trevor@Example.COM", expected: "trevor@example.com", ok: true},
+		{name: "lower", in: float64(1), ok: false},
+		{name: "upper", in: "us", expected: "US", ok: true},
+		{name: "trim", in: "  Trevor \n", expected: "Trevor", ok: true},
+		{name: "len", in: "héllo", expected: float64(5), ok: true},
+		{name: "len", in: []interface{}{"a", "b"}, expected: float64(2), ok: true},
+		{name: "len", in: []string{"a"}, expected: float64(1), ok: true},
+		{name: "len", in: map[string]interface{}{}, expected: float64(0), ok: true},
+		{name: "len", in: map[string]int{"a": 1}, expected: float64(1), ok: true},
+		{name: "len", in: float64(3), ok: false},
+		{name: "len", in: nil, ok: false},
+		{name: "year", in: "2019-03-04T05:06:07-05:00", expected: float64(2019), ok: true},
+		{name: "month", in: "2019-03-04T05:06:07-05:00", expected: float64(3), ok: true},
+		{name: "day", in: "2019-03-04T23:06:07-05:00", expected: float64(4), ok: true},
+		{name: "day", in: time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), expected: float64(31), ok: true},
+		{name: "year", in: float64(0), expected: float64(1970), ok: true},
+		{name: "year", in: "yesterday", ok: false},
+	}
+
+	for _, c := range cases {
+		fn, ok := lookupTransform(c.name)
+		if !ok {
+			t.Fatalf("expected %s to be a transform", c.name)
+		}
+		res, ok := fn(c.in)
+		if ok != c.ok || res != c.expected {
+			t.Fatalf("expected %s(%v) to be %v, %v, got %v, %v", c.name, c.in, c.expected, c.ok, res, ok)
+		}
+	}
+}
+
+func TestRule_evaluateTransforms(t *testing.T) {
+	props := map[string]interface{}{
+		"user": map[string]interface{}{
+			"email":     "Trevor@Example.com",
+			"name":      "  Trevor  ",
+			"tags":      []interface{}{"a", "b", "c"},
+			"signup_at": "2019-03-04T05:06:07Z",
+			"age":       float64(30),
+			"a(b)":      "key",
+		},
+		"orders": []interface{}{
+			map[string]interface{}{"status": "SHIPPED"},
+			map[string]interface{}{"status": "Shipped"},
+		},
+	}
+
+	RegisterTransform("double", func(v interface{}) (interface{}, bool) {
+		f, ok := v.(float64)
+		return f * 2, ok
+	})
+
+	cases := []struct {
+		json     string
+		expected bool
+	}{
+		{json: `{"comparator":"eq","path":"lower(user.email)","value":"trevor@example.com"}`, expected: true},
+		{json: `{"comparator":"eq","path":"trim(user.name)","value":"Trevor"}`, expected: true},
+		{json: `{"comparator":"eq","path":"len(trim(user.name))","value":6}`, expected: true},
+		{json: `{"comparator":"gte","path":"len(user.tags)","value":3}`, expected: true},
+		{json: `{"comparator":"eq","path":"year(user.signup_at)","value":2019}`, expected: true},
+		{json: `{"comparator":"eq","path":"double(user.age)","value":60}`, expected: true},
+		{json: `{"comparator":"eq","path":"lower(orders.*.status)","value":"shipped","match":"all"}`, expected: true},
+		{json: `{"comparator":"gt","path":"year($now)","value":2000}`, expected: true},
+		{json: `{"comparator":"exists","path":"lower(user.age)"}`, expected: false},
+		{json: `{"comparator":"notexists","path":"len(user.phone)"}`, expected: true},
+		{json: `{"comparator":"eq","path":"user[\"a(b)\"]","value":"key"}`, expected: true},
+	}
+
+	for _, c := range cases {
+		var r rule
+		err := json.Unmarshal([]byte(c.json), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+		if res != c.expected {
+			t.Fatalf("expected %s to be %v, got %v", c.json, c.expected, res)
+		}
+
+		// Rules that were not loaded from JSON compile their path when they
+		// are evaluated
+		r.target = nil
+		res = r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+		if res != c.expected {
+			t.Fatalf("expected %s to be %v without a compiled path, got %v", c.json, c.expected, res)
+		}
+	}
+
+	t.Run("time zones", func(t *testing.T) {
+		newYear := time.Date(2025, 1, 1, 0, 30, 0, 0, time.FixedZone("UTC+1", 3600))
+		cases := []struct {
+			now      time.Time
+			expected float64
+		}{
+			{now: newYear.In(time.Local), expected: 2024},
+			{now: newYear, expected: 2025},
+		}
+
+		for _, c := range cases {
+			e, err := NewJSONEngine(json.RawMessage(`{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"year($now)","value":` + fmt.Sprint(c.expected) + `}]}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := c.now
+			e = e.WithClock(func() time.Time { return now })
+			if !e.Evaluate(props) {
+				t.Fatalf("expected year of %v to be %v", c.now, c.expected)
+			}
+		}
+	})
+
+	for _, j := range []string{
+		`{"comparator":"eq","path":"upcase(user.email)","value":"X"}`,
+		`{"comparator":"eq","path":"lower(user.tags[)","value":"X"}`,
+	} {
+		var r rule
+		if err := json.Unmarshal([]byte(j), &r); err == nil {
+			t.Fatalf("expected %s to be an error", j)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+const (
+	// TypeString is the type of strings
+	TypeString = "string"
+	// TypeNumber is the type of all integer and floating point numbers
+	TypeNumber = "number"
+	// TypeBool is the type of booleans
+	TypeBool = "bool"
+	// TypeArray is the type of slices and arrays
+	TypeArray = "array"
+	// TypeObject is the type of maps and structs
+	TypeObject = "object"
+)
+
+// typeOf will return the JSON style type of v, or an empty string if v is
+// null or has no JSON equivalent
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case string:
+		return TypeString
+	case float64, json.Number:
+		return TypeNumber
+	case bool:
+		return TypeBool
+	case []interface{}:
+		return TypeArray
+	case map[string]interface{}:
+		return TypeObject
+	}
+
+	rt := reflect.TypeOf(v)
+	if rt.Kind() == reflect.Ptr {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return ""
+			}
+			rv = rv.Elem()
+		}
+		rt = rv.Type()
+	}
+
+	switch rt.Kind() {
+	case reflect.String:
+		return TypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return TypeNumber
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	case reflect.Map, reflect.Struct:
+		return TypeObject
+	default:
+		return ""
+	}
+}
+
+// isString will return true if a is a string
+func isString(a, b interface{}) bool {
+	return typeOf(a) == TypeString
+}
+
+// isNumber will return true if a is a number of any size
+func isNumber(a, b interface{}) bool {
+	return typeOf(a) == TypeNumber
+}
+
+// isBool will return true if a is a boolean
+func isBool(a, b interface{}) bool {
+	return typeOf(a) == TypeBool
+}
+
+// isArray will return true if a is a slice or an array
+func isArray(a, b interface{}) bool {
+	return typeOf(a) == TypeArray
+}
+
+// isObject will return true if a is a map
+func isObject(a, b interface{}) bool {
+	return typeOf(a) == TypeObject
+}
+
+// isType will return true if a is of the type named by b
+func isType(a, b interface{}) bool {
+	bt, ok := b.(string)
+	if !ok {
+		return false
+	}
+	return typeOf(a) == bt
+}
+
+// parseType will make sure the value of an is_type rule names a known type
+// when the rule is loaded
+func parseType(v interface{}) (interface{}, error) {
+	switch v {
+	case TypeString, TypeNumber, TypeBool, TypeArray, TypeObject:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("is_type value must be one of %s, %s, %s, %s or %s", TypeString, TypeNumber, TypeBool, TypeArray, TypeObject)
+	}
+}
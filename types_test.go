@@ -0,0 +1,94 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTypeOf(t *testing.T) {
+	type custom struct{}
+
+	cases := []struct {
+		val      interface{}
+		expected string
+	}{
+		{val: nil, expected: ""},
+		{val: "a", expected: TypeString},
+		{val: float64(1), expected: TypeNumber},
+		{val: 1, expected: TypeNumber},
+		{val: uint8(1), expected: TypeNumber},
+		{val: float32(1), expected: TypeNumber},
+		{val: json.Number("1"), expected: TypeNumber},
+		{val: true, expected: TypeBool},
+		{val: []interface{}{}, expected: TypeArray},
+		{val: []string{"a"}, expected: TypeArray},
+		{val: [2]int{}, expected: TypeArray},
+		{val: map[string]interface{}{}, expected: TypeObject},
+		{val: map[string]string{}, expected: TypeObject},
+		{val: custom{}, expected: TypeObject},
+		{val: &custom{}, expected: TypeObject},
+		{val: (*custom)(nil), expected: ""},
+		{val: make(chan int), expected: ""},
+	}
+
+	for i, c := range cases {
+		res := typeOf(c.val)
+		if res != c.expected {
+			t.Fatalf("expected case %d to be %q, got %q", i, c.expected, res)
+		}
+	}
+}
+
+func TestTypeComparators(t *testing.T) {
+	comps := map[string]Comparator{
+		TypeString: isString,
+		TypeNumber: isNumber,
+		TypeBool:   isBool,
+		TypeArray:  isArray,
+		TypeObject: isObject,
+	}
+	vals := map[string]interface{}{
+		TypeString: "a",
+		TypeNumber: float64(1),
+		TypeBool:   false,
+		TypeArray:  []interface{}{"a"},
+		TypeObject: map[string]interface{}{"a": "b"},
+	}
+
+	for name, comp := range comps {
+		for typ, val := range vals {
+			res := comp(val, nil)
+			if res != (name == typ) {
+				t.Fatalf("expected is_%s of %s to be %v, got %v", name, typ, name == typ, res)
+			}
+
+			res = isType(val, name)
+			if res != (name == typ) {
+				t.Fatalf("expected is_type %s of %s to be %v, got %v", name, typ, name == typ, res)
+			}
+		}
+	}
+
+	if isType("a", float64(1)) {
+		t.Fatal("expected is_type with a non string value to be false")
+	}
+}
+
+func TestParseType(t *testing.T) {
+	var r rule
+	err := json.Unmarshal([]byte(`{"comparator":"is_type","path":"id","value":"number"}`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal([]byte(`{"comparator":"is_type","path":"id","value":"integer"}`), &r)
+	if err == nil {
+		t.Fatal("expected unknown type to fail to load")
+	}
+}
+
+func BenchmarkIsType(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		isType(float64(1), TypeNumber)
+	}
+}
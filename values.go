@@ -0,0 +1,76 @@
+package grules
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// newReference will return the value that v refers to if v is a reference
+// like {"$path": "..."}, {"$param": "..."} or {"$expr": "..."}. References
+// are single key objects, anything else is a plain value.
+func newReference(v interface{}) (interface{}, bool, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, false, nil
+	}
+
+	if src, ok := m["$path"].(string); ok {
+		p, err := compilePath(src)
+		if err != nil {
+			return nil, false, err
+		}
+		if p.multi {
+			return nil, false, fmt.Errorf("$path %q must refer to a single value", src)
+		}
+		return &pathValue{path: p}, true, nil
+	}
+
+	if p, ok := m["$param"].(string); ok {
+		return paramValue(p), true, nil
+	}
+
+	if src, ok := m["$expr"].(string); ok {
+		e, err := parseExpression(src)
+		if err != nil {
+			return nil, false, err
+		}
+		return &exprValue{expr: e}, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// pathValue is a rule value that refers to another path in the same props,
+// written as {"$path": "limits.daily"}. The path is compiled when the rule is
+// loaded and resolved every time the rule is evaluated, so a rule can
+// compare two values in the props.
+type pathValue struct {
+	path *path
+}
+
+// MarshalJSON will put the path value back into its {"$path": "..."} form
+func (p *pathValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"$path": p.path.src})
+}
+
+// paramValue is a rule value that refers to a parameter, written as
+// {"$param": "max_amount"}. Parameters are given to Engine.EvaluateWith so
+// that rules with the same shape can use different values.
+type paramValue string
+
+// MarshalJSON will put the param value back into its {"$param": "..."} form
+func (p paramValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"$param": string(p)})
+}
+
+// exprValue is a rule value that is computed from the props, written as
+// {"$expr": "limits.daily * 2"}
+type exprValue struct {
+	expr *expression
+}
+
+// MarshalJSON will put the expression value back into its {"$expr": "..."}
+// form
+func (e *exprValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"$expr": e.expr.src})
+}
@@ -0,0 +1,131 @@
+package grules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPathValue(t *testing.T) {
+	props := map[string]interface{}{
+		"order": map[string]interface{}{
+			"shipping_country": "US",
+			"total":            float64(120),
+			"region":           "east",
+		},
+		"user": map[string]interface{}{
+			"billing_country": "US",
+			"regions":         []interface{}{"east", "west"},
+			"addresses":       []interface{}{map[string]interface{}{"region": "east"}},
+			"home": map[string]interface{}{
+				"lat": float64(33.749),
+				"lon": float64(-84.388),
+			},
+		},
+		"limits": map[string]interface{}{
+			"daily": float64(100),
+		},
+	}
+
+	cases := []struct {
+		name     string
+		json     string
+		expected bool
+	}{
+		{name: "eq", json: `{"comparator":"eq","path":"order.shipping_country","value":{"$path":"user.billing_country"}}`, expected: true},
+		{name: "gt", json: `{"comparator":"gt","path":"order.total","value":{"$path":"limits.daily"}}`, expected: true},
+		{name: "lt", json: `{"comparator":"lt","path":"order.total","value":{"$path":"limits.daily"}}`, expected: false},
+		{name: "oneof", json: `{"comparator":"oneof","path":"order.region","value":{"$path":"user.regions"}}`, expected: true},
+		{name: "missing", json: `{"comparator":"eq","path":"order.total","value":{"$path":"limits.weekly"}}`, expected: false},
+		{name: "eq, objects", json: `{"comparator":"eq","path":"order","value":{"$path":"order"}}`, expected: false},
+		{name: "oneof, list of objects", json: `{"comparator":"oneof","path":"order.region","value":{"$path":"user.addresses"}}`, expected: false},
+		{name: "parsed value", json: `{"comparator":"within_radius","path":"user.home","value":{"$path":"limits"}}`, expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r rule
+			err := json.Unmarshal([]byte(c.json), &r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := r.Value.(*pathValue); !ok {
+				t.Fatal("expected value to be a path")
+			}
+			res := r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+			if res != c.expected {
+				t.Fatalf("expected rule to be %v, got %v", c.expected, res)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		j := `{"composites":[{"operator":"and","rules":[{"comparator":"eq","path":"order.shipping_country","value":{"$path":"user.billing_country"}}]}]}`
+		e, err := NewJSONEngine(json.RawMessage(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != j {
+			t.Fatalf("expected json to be same, got %s", b)
+		}
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		var r rule
+		err := json.Unmarshal([]byte(`{"comparator":"eq","path":"a","value":{"$path":"a["}}`), &r)
+		if err == nil {
+			t.Fatal("expected an error for an invalid path")
+		}
+	})
+
+	t.Run("many values", func(t *testing.T) {
+		for _, src := range []string{"m.*", "items[*].qty", "$.items[?(@.qty > 1)]"} {
+			var r rule
+			err := json.Unmarshal([]byte(`{"comparator":"eq","path":"a","value":{"$path":"`+src+`"}}`), &r)
+			if err == nil {
+				t.Fatalf("expected an error for %s, which yields many values", src)
+			}
+		}
+	})
+
+	t.Run("not a path", func(t *testing.T) {
+		var r rule
+		err := json.Unmarshal([]byte(`{"comparator":"eq","path":"a","value":{"$path":"b","other":"c"}}`), &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := r.Value.(*pathValue); ok {
+			t.Fatal("expected value with extra keys to not be a path")
+		}
+	})
+}
+
+func BenchmarkPathValue(b *testing.B) {
+	p, err := compilePath("limits.daily")
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := rule{
+		Comparator: "lt",
+		Path:       "spend.today",
+		Value:      &pathValue{path: p},
+	}
+	props := map[string]interface{}{
+		"spend": map[string]interface{}{
+			"today": float64(50),
+		},
+		"limits": map[string]interface{}{
+			"daily": float64(100),
+		},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.evaluate(newEvaluation(MapSource(props), defaultComparers()))
+	}
+}